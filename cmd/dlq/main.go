@@ -0,0 +1,283 @@
+// dlq показывает задачи face_cut и face_detection из очереди недоставленных и возвращает их
+// в очереди, из которых они пришли.
+//
+//	cd cmd/dlq
+//	export DLQ_URL=https://message-queue.api.cloud.yandex.net/... QUEUE_URL=https://... DETECT_QUEUE_URL=https://...
+//	export AWS_ACCESS_KEY_ID=... AWS_SECRET_ACCESS_KEY=...
+//	go run . list
+//
+// Команды:
+//
+//	list [N]      показать до N задач с причиной ошибки, без N - все; задачи остаются в очереди
+//	redrive [N]   вернуть до N задач в их очереди со сброшенным счётчиком попыток
+//
+// Задача face_cut - с полем bounds, она возвращается в QUEUE_URL; задача face_detection -
+// только фото, она возвращается в DETECT_QUEUE_URL.
+//
+// Задачи, которые попали в очередь недоставленных по redrive policy очереди задач, а не из face_cut,
+// приходят без причины и числа попыток
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	defaultQueueEndpoint = "https://message-queue.api.cloud.yandex.net"
+
+	// receiveBatch - максимум сообщений за один ReceiveMessage
+	receiveBatch = 10
+	// hideTimeout - на сколько секунд list скрывает прочитанные сообщения, чтобы не показать их дважды
+	hideTimeout = 30
+)
+
+// CutterTask - задача face_cut, как её ставит face_detection. У DetectTask из тех же полей
+// есть только ObjectID и Attempt
+type CutterTask struct {
+	Bounds   FaceBounds `json:"bounds"`
+	ObjectID string     `json:"objectID"`
+	Total    int        `json:"total"`
+	Provider string     `json:"provider"`
+	Attempt  int        `json:"attempt,omitempty"`
+}
+
+type FaceBounds struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: dlq [-dlq URL] [-queue URL] [-detect-queue URL] list [N] | redrive [N]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	dlqURL := flag.String("dlq", os.Getenv("DLQ_URL"), "dead-letter queue URL")
+	queueURL := flag.String("queue", os.Getenv("QUEUE_URL"), "face_cut task queue URL, needed for redrive")
+	detectQueueURL := flag.String("detect-queue", os.Getenv("DETECT_QUEUE_URL"), "face_detection retry queue URL, needed for redrive")
+	endpoint := flag.String("endpoint", envOr("SQS_ENDPOINT", defaultQueueEndpoint), "Message Queue endpoint")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *dlqURL == "" || flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	limit := 0
+	if flag.NArg() > 1 {
+		v, err := strconv.Atoi(flag.Arg(1))
+		if err != nil || v <= 0 {
+			log.Fatalf("bad message count %q", flag.Arg(1))
+		}
+		limit = v
+	}
+
+	ctx := context.Background()
+
+	client, err := newClient(ctx, *endpoint)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	switch flag.Arg(0) {
+	case "list":
+		err = list(ctx, client, *dlqURL, limit)
+	case "redrive":
+		if *queueURL == "" || *detectQueueURL == "" {
+			log.Fatalln("redrive needs -queue and -detect-queue, or QUEUE_URL and DETECT_QUEUE_URL")
+		}
+		err = redrive(ctx, client, *dlqURL, *queueURL, *detectQueueURL, limit)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+
+	return fallback
+}
+
+func newClient(ctx context.Context, endpoint string) (*sqs.Client, error) {
+	resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		return aws.Endpoint{
+			URL:           endpoint,
+			SigningRegion: "ru-central1",
+		}, nil
+	})
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithEndpointResolverWithOptions(resolver))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load queue config: %w", err)
+	}
+
+	return sqs.NewFromConfig(cfg), nil
+}
+
+// receive читает сообщения из очереди пачками и передаёт их fn, пока очередь не опустеет
+// или не наберётся limit сообщений (0 - без ограничения)
+func receive(ctx context.Context, client *sqs.Client, queueURL string, limit int, fn func(types.Message) error) (int, error) {
+	count := 0
+	for limit == 0 || count < limit {
+		batch := int32(receiveBatch)
+		if limit > 0 && limit-count < receiveBatch {
+			batch = int32(limit - count)
+		}
+
+		out, err := client.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              &queueURL,
+			MaxNumberOfMessages:   batch,
+			VisibilityTimeout:     hideTimeout,
+			MessageAttributeNames: []string{"All"},
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to receive messages: %w", err)
+		}
+		if len(out.Messages) == 0 {
+			break
+		}
+
+		for _, msg := range out.Messages {
+			if err := fn(msg); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func list(ctx context.Context, client *sqs.Client, dlqURL string, limit int) error {
+	count, err := receive(ctx, client, dlqURL, limit, func(msg types.Message) error {
+		body := aws.ToString(msg.Body)
+
+		fmt.Printf("%s\n", aws.ToString(msg.MessageId))
+		fmt.Printf("  error:    %s\n", attr(msg, "error"))
+		fmt.Printf("  attempts: %s, failed at %s\n", attr(msg, "attempts"), attr(msg, "failed_at"))
+
+		task := &CutterTask{}
+		if err := json.Unmarshal([]byte(body), task); err != nil {
+			fmt.Printf("  body:     %s\n", body)
+			return nil
+		}
+		if !isCutterTask(body) {
+			fmt.Printf("  detect:   %s\n", task.ObjectID)
+			return nil
+		}
+		if task.Bounds.Width == 0 || task.Bounds.Height == 0 {
+			fmt.Printf("  reply:    %s, no faces to cut\n", task.ObjectID)
+			return nil
+		}
+		fmt.Printf("  task:     %s, one of %d faces, at %d,%d %dx%d (%s)\n",
+			task.ObjectID, task.Total, task.Bounds.X, task.Bounds.Y, task.Bounds.Width, task.Bounds.Height, task.Provider)
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d dead-lettered tasks\n", count)
+
+	return nil
+}
+
+// redrive возвращает задачи в очереди, из которых они пришли. Сообщение удаляется из очереди
+// недоставленных только после отправки, поэтому при сбое задача может оказаться в обеих очередях,
+// но не потеряется
+func redrive(ctx context.Context, client *sqs.Client, dlqURL, queueURL, detectQueueURL string, limit int) error {
+	redriven := 0
+	_, err := receive(ctx, client, dlqURL, limit, func(msg types.Message) error {
+		body, err := resetAttempt(aws.ToString(msg.Body))
+		if err != nil {
+			// повтор такой задачи снова закончится ошибкой, она остаётся в очереди для разбора
+			log.Printf("skip %s: %v", aws.ToString(msg.MessageId), err)
+			return nil
+		}
+
+		target := queueURL
+		if !isCutterTask(body) {
+			target = detectQueueURL
+		}
+
+		_, err = client.SendMessage(ctx, &sqs.SendMessageInput{
+			QueueUrl:    &target,
+			MessageBody: &body,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to send message %s: %w", aws.ToString(msg.MessageId), err)
+		}
+
+		_, err = client.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      &dlqURL,
+			ReceiptHandle: msg.ReceiptHandle,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete message %s: %w", aws.ToString(msg.MessageId), err)
+		}
+
+		log.Printf("redrove %s to %s", aws.ToString(msg.MessageId), target)
+		redriven++
+
+		return nil
+	})
+	log.Printf("%d tasks returned", redriven)
+
+	return err
+}
+
+// resetAttempt убирает из задачи счётчик попыток, остальные поля остаются как есть
+func resetAttempt(body string) (string, error) {
+	task := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(body), &task); err != nil {
+		return "", fmt.Errorf("body is not a task: %w", err)
+	}
+	delete(task, "attempt")
+
+	out, err := json.Marshal(task)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// isCutterTask отличает задачу face_cut от задачи face_detection: только у неё есть bounds
+func isCutterTask(body string) bool {
+	task := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(body), &task); err != nil {
+		return false
+	}
+	_, ok := task["bounds"]
+
+	return ok
+}
+
+func attr(msg types.Message, name string) string {
+	if v, ok := msg.MessageAttributes[name]; ok && v.StringValue != nil {
+		return *v.StringValue
+	}
+
+	return "-"
+}
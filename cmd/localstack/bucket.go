@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const pollInterval = time.Second
+
+// как suffix триггеров input_trigger* в main.tf
+var objectSuffixes = []string{".jpg", ".png", ".webp"}
+
+func isObject(name string) bool {
+	for _, suffix := range objectSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Bucket - каталог вместо бакета Object Storage. Новые изображения в нём запускают функцию,
+// как триггер бакета; файлы из inputDir переносятся в бакет
+type Bucket struct {
+	id       string
+	dir      string
+	inputDir string
+	seen     map[string]bool
+}
+
+func NewBucket(id, dir, inputDir string) (*Bucket, error) {
+	for _, d := range []string{dir, inputDir} {
+		if err := os.MkdirAll(d, 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	b := &Bucket{
+		id:       id,
+		dir:      dir,
+		inputDir: inputDir,
+		seen:     map[string]bool{},
+	}
+
+	// объекты, лежавшие в бакете до запуска, не обрабатываются повторно
+	objects, err := b.list(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, obj := range objects {
+		b.seen[obj] = true
+	}
+
+	return b, nil
+}
+
+func (b *Bucket) list(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.Type().IsRegular() && isObject(e.Name()) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Watch раз в pollInterval переносит файлы из inputDir и отдаёт новые объекты в fn
+func (b *Bucket) Watch(ctx context.Context, fn *Function) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := b.importInput(); err != nil {
+			log.Printf("failed to import input: %v", err)
+		}
+
+		objects, err := b.list(b.dir)
+		if err != nil {
+			log.Printf("failed to list bucket: %v", err)
+			continue
+		}
+
+		var created []string
+		for _, obj := range objects {
+			if !b.seen[obj] {
+				b.seen[obj] = true
+				created = append(created, obj)
+			}
+		}
+
+		if len(created) == 0 {
+			continue
+		}
+
+		event, err := objectCreateEvent(b.id, created...)
+		if err != nil {
+			log.Printf("failed to build object event: %v", err)
+			continue
+		}
+
+		log.Printf("new objects: %v", created)
+
+		if _, err := fn.Invoke(ctx, event); err != nil {
+			log.Printf("%s failed on %v: %v", fn.Name, created, err)
+		}
+	}
+}
+
+func (b *Bucket) importInput() error {
+	files, err := b.list(b.inputDir)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range files {
+		if err := moveFile(path.Join(b.inputDir, name), path.Join(b.dir, name)); err != nil {
+			return fmt.Errorf("failed to move %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// moveFile переносит файл, в том числе между разными файловыми системами
+func moveFile(from, to string) error {
+	if err := os.Rename(from, to); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.Create(to)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+
+	return os.Remove(from)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"plugin"
+	"reflect"
+)
+
+const (
+	funcFaceDetection = "face_detection"
+	funcFaceCut       = "face_cut"
+	funcBot           = "tg_bot"
+	funcGateway       = "api_gw"
+)
+
+var functionNames = []string{funcFaceDetection, funcFaceCut, funcBot, funcGateway}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	bytesType   = reflect.TypeOf([]byte(nil))
+)
+
+// Function - Handler облачной функции, загруженный как Go plugin.
+// Вызывается так же, как в рантайме Yandex Cloud Functions: JSON события на вход, JSON ответа на выход
+type Function struct {
+	Name    string
+	handler reflect.Value
+}
+
+// buildFunction собирает функцию из srcDir/name в pluginsDir/name.so
+func buildFunction(srcDir, pluginsDir, name string) (string, error) {
+	dir := path.Join(srcDir, name)
+	out, err := filepath.Abs(path.Join(pluginsDir, name+".so"))
+	if err != nil {
+		return "", err
+	}
+
+	args := []string{"build", "-buildmode=plugin", "-o", out}
+	if _, err := os.Stat(path.Join(dir, "go.mod")); err != nil {
+		// функция без go.mod собирается из списка файлов
+		files, err := filepath.Glob(path.Join(dir, "*.go"))
+		if err != nil {
+			return "", err
+		}
+		for _, f := range files {
+			args = append(args, filepath.Base(f))
+		}
+	} else {
+		args = append(args, ".")
+	}
+
+	log.Printf("building %s", name)
+
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to build %s: %w", name, err)
+	}
+
+	return out, nil
+}
+
+func loadFunction(name, file string) (*Function, error) {
+	p, err := plugin.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", file, err)
+	}
+
+	sym, err := p.Lookup("Handler")
+	if err != nil {
+		return nil, fmt.Errorf("%s has no Handler: %w", name, err)
+	}
+
+	handler := reflect.ValueOf(sym)
+	t := handler.Type()
+	if t.Kind() != reflect.Func ||
+		t.NumIn() != 2 || t.In(0) != contextType ||
+		t.NumOut() != 2 || t.Out(1) != errorType {
+		return nil, fmt.Errorf("%s: unsupported Handler signature %s", name, t)
+	}
+
+	return &Function{Name: name, handler: handler}, nil
+}
+
+// Invoke вызывает Handler с событием payload и возвращает ответ в JSON
+func (f *Function) Invoke(ctx context.Context, payload []byte) ([]byte, error) {
+	argType := f.handler.Type().In(1)
+
+	var arg reflect.Value
+	if argType == bytesType {
+		arg = reflect.ValueOf(payload)
+	} else {
+		ptr := reflect.New(argType)
+		if err := json.Unmarshal(payload, ptr.Interface()); err != nil {
+			return nil, fmt.Errorf("failed to parse %s event: %w", f.Name, err)
+		}
+		arg = ptr.Elem()
+	}
+
+	out := f.handler.Call([]reflect.Value{reflect.ValueOf(ctx), arg})
+	if err, _ := out[1].Interface().(error); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(out[0].Interface())
+}
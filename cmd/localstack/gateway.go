@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Структура запроса API Gateway v1, как её получают tg_bot и api_gw
+type APIGatewayRequest struct {
+	HTTPMethod string `json:"httpMethod"`
+	Path       string `json:"path"`
+
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+
+	QueryStringParameters           map[string]string   `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string `json:"multiValueQueryStringParameters"`
+
+	Body            string `json:"body"`
+	IsBase64Encoded bool   `json:"isBase64Encoded,omitempty"`
+}
+
+// APIGatewayResponse - ответ функции; Body у функций бывает строкой или []byte,
+// в JSON это всегда строка
+type APIGatewayResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
+}
+
+// Gateway вызывает функцию на каждый HTTP-запрос, как интеграция API Gateway
+type Gateway struct {
+	fn *Function
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req := &APIGatewayRequest{
+		HTTPMethod:                      r.Method,
+		Path:                            r.URL.Path,
+		Headers:                         map[string]string{},
+		MultiValueHeaders:               r.Header,
+		QueryStringParameters:           map[string]string{},
+		MultiValueQueryStringParameters: r.URL.Query(),
+		Body:                            string(body),
+	}
+	for k := range r.Header {
+		req.Headers[k] = r.Header.Get(k)
+	}
+	// net/http убирает Host из заголовков, а api_gw строит по нему ссылки
+	req.Headers["Host"] = r.Host
+	for k, v := range r.URL.Query() {
+		req.QueryStringParameters[k] = v[0]
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	out, err := g.fn.Invoke(r.Context(), payload)
+	if err != nil {
+		log.Printf("%s failed: %v", g.fn.Name, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	resp := &APIGatewayResponse{}
+	if err := json.Unmarshal(out, resp); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	respBody := []byte(resp.Body)
+	if resp.IsBase64Encoded {
+		respBody, err = base64.StdEncoding.DecodeString(resp.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	for k, values := range resp.MultiValueHeaders {
+		for _, v := range values {
+			w.Header().Add(k, v)
+		}
+	}
+
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	w.WriteHeader(status)
+	_, _ = w.Write(respBody)
+}
+
+// Replay передаёт функции тело запроса как есть: так можно повторить сохранённый
+// конверт триггера. Путь - /functions/<имя функции>
+type Replay struct {
+	functions map[string]*Function
+}
+
+func (h *Replay) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	fn, ok := h.functions[strings.TrimPrefix(r.URL.Path, "/functions/")]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	out, err := fn.Invoke(r.Context(), payload)
+	if err != nil {
+		log.Printf("%s failed: %v", fn.Name, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(out)
+}
@@ -0,0 +1,126 @@
+// localstack запускает весь конвейер на одной машине без Yandex Cloud.
+//
+// Функции из internal собираются как Go plugins и вызываются с теми же JSON-событиями,
+// что присылают триггеры: бакет заменён каталогом, Message Queue - очередью в памяти,
+// YDB - репозиторием в памяти (или локальным YDB, если задан YDB_URL).
+//
+//	cd cmd/localstack && go run .
+//
+// Новые изображения из -input переносятся в бакет и запускают face_detection, найденные лица
+// через очередь попадают в face_cut, фото для повторного поиска лиц - снова в face_detection. Webhook бота - POST /bot, API Gateway - GET /?face=,
+// сохранённый конверт события можно повторить через POST /functions/<имя функции>
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	bucketID      = "vvot14-photo"
+	queueID       = "vvot14-tasks"
+	detectQueueID = "vvot14-detect"
+	dlqID         = "vvot14-tasks-dlq"
+)
+
+func setDefaultEnv(key, value string) {
+	if os.Getenv(key) == "" {
+		_ = os.Setenv(key, value)
+	}
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8080", "HTTP address for the bot webhook, API Gateway and queue")
+	srcDir := flag.String("src", "../../internal", "directory with function sources")
+	pluginsDir := flag.String("plugins", "build", "directory for built function plugins")
+	build := flag.Bool("build", true, "build function plugins before start")
+	storageDir := flag.String("storage", "storage", "directory used instead of the buckets")
+	inputDir := flag.String("input", "input", "directory watched for new photos")
+	flag.Parse()
+
+	storage, err := filepath.Abs(*storageDir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := os.MkdirAll(path.Join(storage, "faces"), 0o755); err != nil {
+		log.Fatalln(err)
+	}
+	if err := os.MkdirAll(*pluginsDir, 0o755); err != nil {
+		log.Fatalln(err)
+	}
+
+	// функции читают окружение при загрузке, поэтому оно задаётся до plugin.Open
+	setDefaultEnv("STORAGE", "fs")
+	setDefaultEnv("STORAGE_DIR", storage)
+	if os.Getenv("YDB_URL") == "" {
+		setDefaultEnv("REPOSITORY", "memory")
+	}
+	setDefaultEnv("DETECTOR", "fake")
+	setDefaultEnv("API_GW_URL", *addr)
+	setDefaultEnv("URL_SIGNING_KEY", "local")
+	setDefaultEnv("API_TOKEN", "local")
+	setDefaultEnv("SQS_ENDPOINT", "http://"+*addr+"/sqs")
+	setDefaultEnv("QUEUE_URL", "http://"+*addr+"/sqs/"+queueID)
+	setDefaultEnv("DETECT_QUEUE_URL", "http://"+*addr+"/sqs/"+detectQueueID)
+	setDefaultEnv("DLQ_URL", "http://"+*addr+"/sqs/"+dlqID)
+	setDefaultEnv("AWS_ACCESS_KEY_ID", "local")
+	setDefaultEnv("AWS_SECRET_ACCESS_KEY", "local")
+	setDefaultEnv("AWS_REGION", "ru-central1")
+
+	functions := map[string]*Function{}
+	for _, name := range functionNames {
+		file := path.Join(*pluginsDir, name+".so")
+		if *build {
+			if file, err = buildFunction(*srcDir, *pluginsDir, name); err != nil {
+				log.Fatalln(err)
+			}
+		}
+
+		fn, err := loadFunction(name, file)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		functions[name] = fn
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	bucket, err := NewBucket(bucketID, path.Join(storage, "images"), *inputDir)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	queue := NewQueue(queueID)
+	detectQueue := NewQueue(detectQueueID)
+	queues := Queues{queueID: queue, detectQueueID: detectQueue, dlqID: NewQueue(dlqID)}
+
+	go bucket.Watch(ctx, functions[funcFaceDetection])
+	go detectQueue.Consume(ctx, functions[funcFaceDetection])
+	go queue.Consume(ctx, functions[funcFaceCut])
+
+	mux := http.NewServeMux()
+	mux.Handle("/sqs", queues)
+	mux.Handle("/sqs/", queues)
+	mux.Handle("/functions/", &Replay{functions: functions})
+	mux.Handle("/bot", &Gateway{fn: functions[funcBot]})
+	mux.Handle("/", &Gateway{fn: functions[funcGateway]})
+
+	server := &http.Server{Addr: *addr, Handler: mux}
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	log.Printf("localstack is listening on %s, drop photos into %s", *addr, *inputDir)
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatalln(err)
+	}
+}
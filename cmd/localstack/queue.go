@@ -0,0 +1,269 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// как batch_size и batch_cutoff триггера ymq_trigger в main.tf
+	queueBatchSize   = 5
+	queueBatchCutoff = time.Second
+
+	// как visibility_timeout_seconds по умолчанию в Message Queue
+	queueVisibilityTimeout = 30 * time.Second
+
+	sqsTargetPrefix = "AmazonSQS."
+)
+
+// Queue - очередь в памяти вместо Yandex Message Queue. Принимает запросы по JSON-протоколу
+// SQS и передаёт сообщения функции пачками, как триггер очереди
+type Queue struct {
+	id       string
+	messages chan QueueMessage
+
+	mu       sync.Mutex
+	inflight map[string]QueueMessage
+}
+
+func NewQueue(id string) *Queue {
+	return &Queue{
+		id:       id,
+		messages: make(chan QueueMessage, 1024),
+		inflight: map[string]QueueMessage{},
+	}
+}
+
+// Queues направляет запросы SQS в очередь по последнему сегменту QueueUrl
+type Queues map[string]*Queue
+
+// sqsInput - поля всех поддерживаемых методов, у каждого метода свои
+type sqsInput struct {
+	QueueUrl          string                      `json:"QueueUrl"`
+	MessageBody       string                      `json:"MessageBody"`
+	DelaySeconds      int                         `json:"DelaySeconds"`
+	MessageAttributes map[string]messageAttribute `json:"MessageAttributes"`
+
+	MaxNumberOfMessages int  `json:"MaxNumberOfMessages"`
+	WaitTimeSeconds     int  `json:"WaitTimeSeconds"`
+	VisibilityTimeout   *int `json:"VisibilityTimeout"`
+
+	ReceiptHandle string `json:"ReceiptHandle"`
+}
+
+type messageAttribute struct {
+	DataType    string `json:"DataType"`
+	StringValue string `json:"StringValue,omitempty"`
+}
+
+type sendMessageOutput struct {
+	MessageId        string `json:"MessageId"`
+	MD5OfMessageBody string `json:"MD5OfMessageBody"`
+}
+
+type receivedMessage struct {
+	MessageId         string         `json:"MessageId"`
+	ReceiptHandle     string         `json:"ReceiptHandle"`
+	MD5OfBody         string         `json:"MD5OfBody"`
+	Body              string         `json:"Body"`
+	MessageAttributes map[string]any `json:"MessageAttributes,omitempty"`
+}
+
+type receiveMessageOutput struct {
+	Messages []receivedMessage `json:"Messages"`
+}
+
+type sqsError struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+}
+
+func writeSQSError(w http.ResponseWriter, status int, errType, msg string) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(&sqsError{Type: errType, Message: msg})
+}
+
+func writeSQS(w http.ResponseWriter, out interface{}) {
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	_ = json.NewEncoder(w).Encode(out)
+}
+
+// ServeHTTP реализует методы SQS, которыми пользуются функции и cmd/dlq:
+// SendMessage, ReceiveMessage и DeleteMessage
+func (qs Queues) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	in := &sqsInput{}
+	if err := json.NewDecoder(r.Body).Decode(in); err != nil {
+		writeSQSError(w, http.StatusBadRequest, "com.amazonaws.sqs#InvalidParameterValue", err.Error())
+		return
+	}
+
+	q, ok := qs[path.Base(in.QueueUrl)]
+	if !ok {
+		writeSQSError(w, http.StatusBadRequest, "com.amazonaws.sqs#QueueDoesNotExist",
+			fmt.Sprintf("queue %q does not exist", in.QueueUrl))
+		return
+	}
+
+	switch target := r.Header.Get("X-Amz-Target"); target {
+	case sqsTargetPrefix + "SendMessage":
+		writeSQS(w, q.send(in))
+	case sqsTargetPrefix + "ReceiveMessage":
+		writeSQS(w, q.receive(r.Context(), in))
+	case sqsTargetPrefix + "DeleteMessage":
+		if !q.delete(in.ReceiptHandle) {
+			writeSQSError(w, http.StatusBadRequest, "com.amazonaws.sqs#ReceiptHandleIsInvalid",
+				fmt.Sprintf("receipt handle %q is not in flight", in.ReceiptHandle))
+			return
+		}
+		writeSQS(w, struct{}{})
+	default:
+		writeSQSError(w, http.StatusBadRequest, "com.amazonaws.sqs#UnsupportedOperation",
+			fmt.Sprintf("unsupported operation %q", strings.TrimPrefix(target, sqsTargetPrefix)))
+	}
+}
+
+func (q *Queue) send(in *sqsInput) *sendMessageOutput {
+	sum := md5.Sum([]byte(in.MessageBody))
+	msg := QueueMessage{
+		MessageID: newEventID(),
+		MD5OfBody: hex.EncodeToString(sum[:]),
+		Body:      in.MessageBody,
+		Attributes: map[string]string{
+			"SentTimestamp": strconv.FormatInt(time.Now().UnixMilli(), 10),
+		},
+		MessageAttributes: map[string]any{},
+	}
+	for name, attr := range in.MessageAttributes {
+		msg.MessageAttributes[name] = attr
+	}
+
+	if in.DelaySeconds > 0 {
+		time.AfterFunc(time.Duration(in.DelaySeconds)*time.Second, func() { q.messages <- msg })
+	} else {
+		q.messages <- msg
+	}
+
+	return &sendMessageOutput{
+		MessageId:        msg.MessageID,
+		MD5OfMessageBody: msg.MD5OfBody,
+	}
+}
+
+// receive отдаёт до MaxNumberOfMessages сообщений и прячет их на VisibilityTimeout:
+// сообщение, которое не удалили за это время, возвращается в очередь
+func (q *Queue) receive(ctx context.Context, in *sqsInput) *receiveMessageOutput {
+	limit := in.MaxNumberOfMessages
+	if limit <= 0 {
+		limit = 1
+	}
+	visibility := queueVisibilityTimeout
+	if in.VisibilityTimeout != nil {
+		visibility = time.Duration(*in.VisibilityTimeout) * time.Second
+	}
+
+	out := &receiveMessageOutput{Messages: []receivedMessage{}}
+
+	wait := time.After(time.Duration(in.WaitTimeSeconds) * time.Second)
+	for len(out.Messages) < limit {
+		var msg QueueMessage
+		select {
+		case msg = <-q.messages:
+		default:
+			// ждать WaitTimeSeconds имеет смысл только первого сообщения
+			if len(out.Messages) > 0 {
+				return out
+			}
+
+			select {
+			case msg = <-q.messages:
+			case <-wait:
+				return out
+			case <-ctx.Done():
+				return out
+			}
+		}
+
+		receipt := newEventID()
+		q.mu.Lock()
+		q.inflight[receipt] = msg
+		q.mu.Unlock()
+
+		time.AfterFunc(visibility, func() {
+			if q.delete(receipt) {
+				q.messages <- msg
+			}
+		})
+
+		out.Messages = append(out.Messages, receivedMessage{
+			MessageId:         msg.MessageID,
+			ReceiptHandle:     receipt,
+			MD5OfBody:         msg.MD5OfBody,
+			Body:              msg.Body,
+			MessageAttributes: msg.MessageAttributes,
+		})
+	}
+
+	return out
+}
+
+// delete убирает полученное сообщение; false - если его уже удалили или вернули в очередь
+func (q *Queue) delete(receipt string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.inflight[receipt]; !ok {
+		return false
+	}
+	delete(q.inflight, receipt)
+
+	return true
+}
+
+// Consume передаёт сообщения в fn, пока не отменён ctx. Пачка уходит, когда набралось
+// queueBatchSize сообщений или прошло queueBatchCutoff с первого из них
+func (q *Queue) Consume(ctx context.Context, fn *Function) {
+	for {
+		var batch []QueueMessage
+
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-q.messages:
+			batch = append(batch, msg)
+		}
+
+		cutoff := time.After(queueBatchCutoff)
+	collect:
+		for len(batch) < queueBatchSize {
+			select {
+			case msg := <-q.messages:
+				batch = append(batch, msg)
+			case <-cutoff:
+				break collect
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		event, err := queueEvent(q.id, batch...)
+		if err != nil {
+			log.Printf("failed to build queue event: %v", err)
+			continue
+		}
+
+		if _, err := fn.Invoke(ctx, event); err != nil {
+			log.Printf("%s failed on %d messages: %v", fn.Name, len(batch), err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const (
+	eventObjectCreate = "yandex.cloud.events.storage.ObjectCreate"
+	eventQueueMessage = "yandex.cloud.events.messagequeue.QueueMessage"
+
+	localCloudID  = "local"
+	localFolderID = "local"
+)
+
+// Конверты событий повторяют то, что триггеры Yandex Cloud передают функциям
+
+type EventMetadata struct {
+	EventID        string      `json:"event_id"`
+	EventType      string      `json:"event_type"`
+	CreatedAt      time.Time   `json:"created_at"`
+	TracingContext interface{} `json:"tracing_context"`
+	CloudID        string      `json:"cloud_id"`
+	FolderID       string      `json:"folder_id"`
+}
+
+type ObjectDetails struct {
+	BucketID string `json:"bucket_id"`
+	ObjectID string `json:"object_id"`
+}
+
+type QueueMessage struct {
+	MessageID              string            `json:"message_id"`
+	MD5OfBody              string            `json:"md5_of_body"`
+	Body                   string            `json:"body"`
+	Attributes             map[string]string `json:"attributes"`
+	MessageAttributes      map[string]any    `json:"message_attributes"`
+	MD5OfMessageAttributes string            `json:"md5_of_message_attributes"`
+}
+
+type QueueDetails struct {
+	QueueID string       `json:"queue_id"`
+	Message QueueMessage `json:"message"`
+}
+
+type TriggerMessage struct {
+	EventMetadata EventMetadata `json:"event_metadata"`
+	Details       interface{}   `json:"details"`
+}
+
+type TriggerEvent struct {
+	Messages []TriggerMessage `json:"messages"`
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func newMetadata(eventType string) EventMetadata {
+	return EventMetadata{
+		EventID:   newEventID(),
+		EventType: eventType,
+		CreatedAt: time.Now().UTC(),
+		CloudID:   localCloudID,
+		FolderID:  localFolderID,
+	}
+}
+
+// objectCreateEvent - событие триггера бакета о новых объектах
+func objectCreateEvent(bucket string, objects ...string) ([]byte, error) {
+	event := TriggerEvent{}
+	for _, obj := range objects {
+		event.Messages = append(event.Messages, TriggerMessage{
+			EventMetadata: newMetadata(eventObjectCreate),
+			Details: ObjectDetails{
+				BucketID: bucket,
+				ObjectID: obj,
+			},
+		})
+	}
+
+	return json.Marshal(event)
+}
+
+// queueEvent - событие триггера Message Queue с пачкой сообщений
+func queueEvent(queueID string, messages ...QueueMessage) ([]byte, error) {
+	event := TriggerEvent{}
+	for _, msg := range messages {
+		event.Messages = append(event.Messages, TriggerMessage{
+			EventMetadata: newMetadata(eventQueueMessage),
+			Details: QueueDetails{
+				QueueID: queueID,
+				Message: msg,
+			},
+		})
+	}
+
+	return json.Marshal(event)
+}
@@ -0,0 +1,26 @@
+module 2hw/migrate
+
+go 1.21.0
+
+require (
+	2hw/repository v0.0.0
+	github.com/ydb-platform/ydb-go-sdk/v3 v3.95.5
+	github.com/ydb-platform/ydb-go-yc-metadata v0.6.1
+)
+
+require (
+	github.com/golang-jwt/jwt/v4 v4.4.1 // indirect
+	github.com/golang/protobuf v1.5.3 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	github.com/jonboulle/clockwork v0.3.0 // indirect
+	github.com/ydb-platform/ydb-go-genproto v0.0.0-20241112172322-ea1f63298f77 // indirect
+	golang.org/x/net v0.23.0 // indirect
+	golang.org/x/sync v0.6.0 // indirect
+	golang.org/x/sys v0.18.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80 // indirect
+	google.golang.org/grpc v1.62.1 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
+)
+
+replace 2hw/repository => ../../internal/repository
@@ -0,0 +1,182 @@
+// migrate применяет и откатывает миграции схемы YDB из internal/repository/migrations.
+//
+//	cd cmd/migrate
+//	YDB_URL=grpcs://... YDB_ACCESS_TOKEN=$(yc iam create-token) go run . up
+//
+// Команды:
+//
+//	status        текущая версия базы и миграции, которые ещё не применены
+//	up [N]        применить миграции до версии N, без N - все
+//	down [N]      откатить миграции новее версии N, без N - только последнюю
+//
+// Функции проверяют версию схемы при старте и не работают, пока база не обновлена
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"2hw/repository"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	yc "github.com/ydb-platform/ydb-go-yc-metadata"
+)
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate [-url grpcs://...] status | up [N] | down [N]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	ydbURL := flag.String("url", os.Getenv("YDB_URL"), "YDB connection string")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *ydbURL == "" || flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	target := -1
+	if flag.NArg() > 1 {
+		v, err := strconv.Atoi(flag.Arg(1))
+		if err != nil || v < 0 {
+			log.Fatalf("bad version %q", flag.Arg(1))
+		}
+		target = v
+	}
+
+	ctx := context.Background()
+
+	db, err := ydb.Open(ctx, *ydbURL, credentials(*ydbURL)...)
+	if err != nil {
+		log.Fatalf("failed to connect to %s: %v", *ydbURL, err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+
+	repo := repository.New(db.Query())
+
+	switch cmd := flag.Arg(0); cmd {
+	case "status":
+		err = status(ctx, repo)
+	case "up":
+		err = up(ctx, repo, target)
+	case "down":
+		err = down(ctx, repo, target)
+	default:
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
+
+// credentials: токен из YDB_ACCESS_TOKEN, без авторизации для локального grpc://,
+// иначе - метаданные виртуальной машины Yandex Cloud
+func credentials(ydbURL string) []ydb.Option {
+	if token := os.Getenv("YDB_ACCESS_TOKEN"); token != "" {
+		return []ydb.Option{ydb.WithAccessTokenCredentials(token)}
+	}
+
+	if strings.HasPrefix(ydbURL, "grpc://") {
+		return nil
+	}
+
+	return []ydb.Option{
+		yc.WithInternalCA(),
+		yc.WithCredentials(),
+	}
+}
+
+func status(ctx context.Context, repo *repository.YDB) error {
+	migrations, err := repository.Migrations()
+	if err != nil {
+		return err
+	}
+
+	current, err := repo.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("database version: %d, latest: %d\n", current, repository.LatestVersion())
+	for _, m := range migrations {
+		state := "applied"
+		if m.Version > current {
+			state = "pending"
+		}
+		fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, state)
+	}
+
+	return nil
+}
+
+func up(ctx context.Context, repo *repository.YDB, target int) error {
+	applied, err := repo.MigrateUp(ctx, target)
+	for _, m := range applied {
+		fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("database is up to date")
+	}
+
+	return nil
+}
+
+func down(ctx context.Context, repo *repository.YDB, target int) error {
+	if target < 0 {
+		prev, err := previousVersion(ctx, repo)
+		if err != nil {
+			return err
+		}
+		target = prev
+	}
+
+	reverted, err := repo.MigrateDown(ctx, target)
+	for _, m := range reverted {
+		fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(reverted) == 0 {
+		fmt.Println("nothing to revert")
+	}
+
+	return nil
+}
+
+// previousVersion - версия перед последней применённой миграцией
+func previousVersion(ctx context.Context, repo *repository.YDB) (int, error) {
+	migrations, err := repository.Migrations()
+	if err != nil {
+		return 0, err
+	}
+
+	current, err := repo.SchemaVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	prev := 0
+	for _, m := range migrations {
+		if m.Version < current {
+			prev = m.Version
+		}
+	}
+
+	return prev, nil
+}
@@ -0,0 +1,279 @@
+// reconcile сверяет бакет лиц с таблицами names и relations и чинит расхождения.
+//
+//	cd cmd/reconcile
+//	export YDB_URL=grpcs://... YDB_ACCESS_TOKEN=$(yc iam create-token)
+//	export STORAGE=s3 FACES_BUCKET=... IMAGES_BUCKET=... AWS_ACCESS_KEY_ID=... AWS_SECRET_ACCESS_KEY=...
+//	go run .        # только отчёт
+//	go run . -fix   # отчёт и исправление
+//
+// Расхождения и исправления:
+//
+//	no-file       строки есть, файла лица нет          удалить строки names и relations
+//	no-relation   лицо есть только в names              удалить строку и файл: фото неизвестно
+//	no-name       лицо есть только в relations          добавить строку names без имени
+//	no-rows       файл лица без строк в таблицах        удалить файл, если он старше -min-age
+//	stale-derived вариант api_gw без исходника          удалить вариант
+//
+// Варианты api_gw лежат в бакете лиц под derived/<bucket>/<name>/ и лицами не считаются.
+// Исходник варианта лица ищется в бакете лиц, варианта фото - в бакете фото.
+//
+// Лицо без фото face_cut создаст заново, если задачу вернуть в очередь через cmd/dlq
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"2hw/repository"
+	"2hw/storage"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	yc "github.com/ydb-platform/ydb-go-yc-metadata"
+)
+
+const (
+	kindNoFile     = "no-file"
+	kindNoRelation = "no-relation"
+	kindNoName     = "no-name"
+	kindNoRows     = "no-rows"
+	kindStale      = "stale-derived"
+)
+
+// derivedPrefix - варианты изображений api_gw, ключ derived/<bucket>/<name>/<variant>
+// (derivedPrefix и derivedKey в internal/api_gw)
+const derivedPrefix = "derived/"
+
+// defaultMinAge: face_cut сохраняет файл раньше строк, свежий файл без строк может быть ещё в работе
+const defaultMinAge = time.Hour
+
+type finding struct {
+	kind    string
+	faceID  string
+	imageID string
+	// key - ключ варианта api_gw, только у stale-derived
+	key string
+}
+
+func (f finding) String() string {
+	if f.key != "" {
+		return fmt.Sprintf("%-13s %s", f.kind, f.key)
+	}
+
+	return fmt.Sprintf("%-13s %s %s", f.kind, f.faceID, f.imageID)
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), "usage: reconcile [-url grpcs://...] [-fix] [-min-age 1h]\n")
+	flag.PrintDefaults()
+}
+
+func main() {
+	ydbURL := flag.String("url", os.Getenv("YDB_URL"), "YDB connection string")
+	fix := flag.Bool("fix", false, "repair found orphans, without it only report them")
+	minAge := flag.Duration("min-age", defaultMinAge, "files without rows younger than this are left alone")
+	flag.Usage = usage
+	flag.Parse()
+
+	if *ydbURL == "" || flag.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	db, err := ydb.Open(ctx, *ydbURL, credentials(*ydbURL)...)
+	if err != nil {
+		log.Fatalf("failed to connect to %s: %v", *ydbURL, err)
+	}
+	defer func() {
+		_ = db.Close(ctx)
+	}()
+
+	repo := repository.New(db.Query())
+	if err := repo.CheckSchema(ctx); err != nil {
+		log.Fatalln(err)
+	}
+
+	faces, err := storage.FromEnv(ctx, storage.Faces)
+	if err != nil {
+		log.Fatalf("failed to open faces storage: %v", err)
+	}
+
+	images, err := storage.FromEnv(ctx, storage.Images)
+	if err != nil {
+		log.Fatalf("failed to open images storage: %v", err)
+	}
+
+	findings, err := reconcile(ctx, repo, faces, images, *minAge)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	counts := map[string]int{}
+	failed := 0
+	for _, f := range findings {
+		counts[f.kind]++
+		fmt.Println(f)
+
+		if !*fix {
+			continue
+		}
+		if err := repair(ctx, repo, faces, f); err != nil {
+			log.Printf("failed to repair %s: %v", f, err)
+			failed++
+		}
+	}
+
+	fmt.Printf("%s: %d, %s: %d, %s: %d, %s: %d, %s: %d\n",
+		kindNoFile, counts[kindNoFile], kindNoRelation, counts[kindNoRelation],
+		kindNoName, counts[kindNoName], kindNoRows, counts[kindNoRows], kindStale, counts[kindStale])
+
+	switch {
+	case failed > 0:
+		log.Fatalf("%d of %d repairs failed", failed, len(findings))
+	case *fix:
+		fmt.Printf("repaired %d\n", len(findings))
+	case len(findings) > 0:
+		fmt.Println("run with -fix to repair")
+	}
+}
+
+// credentials: токен из YDB_ACCESS_TOKEN, без авторизации для локального grpc://,
+// иначе - метаданные виртуальной машины Yandex Cloud
+func credentials(ydbURL string) []ydb.Option {
+	if token := os.Getenv("YDB_ACCESS_TOKEN"); token != "" {
+		return []ydb.Option{ydb.WithAccessTokenCredentials(token)}
+	}
+
+	if strings.HasPrefix(ydbURL, "grpc://") {
+		return nil
+	}
+
+	return []ydb.Option{
+		yc.WithInternalCA(),
+		yc.WithCredentials(),
+	}
+}
+
+// reconcile сравнивает строки names и relations с файлами бакета лиц
+func reconcile(ctx context.Context, repo *repository.YDB, faces, images storage.BlobStore, minAge time.Duration) ([]finding, error) {
+	refs, err := repo.FaceRefs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := faces.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list faces: %w", err)
+	}
+
+	files := make(map[string]storage.ObjectInfo, len(objects))
+	var derived []storage.ObjectInfo
+	for _, obj := range objects {
+		if strings.HasPrefix(obj.Key, derivedPrefix) {
+			derived = append(derived, obj)
+			continue
+		}
+		files[obj.Key] = obj
+	}
+
+	var findings []finding
+	// у одного фото обычно несколько вариантов, фото проверяется один раз
+	imageExists := map[string]bool{}
+	for _, obj := range derived {
+		bucket, name, ok := derivedSource(obj.Key)
+		if !ok {
+			continue
+		}
+
+		var exists bool
+		switch bucket {
+		case storage.Faces:
+			_, exists = files[name]
+		case storage.Images:
+			if exists, ok = imageExists[name]; !ok {
+				_, err := images.Stat(ctx, name)
+				if err != nil && !errors.Is(err, storage.ErrNotFound) {
+					return nil, fmt.Errorf("failed to stat image %s: %w", name, err)
+				}
+				exists = err == nil
+				imageExists[name] = exists
+			}
+		default:
+			continue
+		}
+
+		if !exists {
+			findings = append(findings, finding{kind: kindStale, key: obj.Key})
+		}
+	}
+
+	for _, ref := range refs {
+		_, hasFile := files[ref.FaceID]
+		delete(files, ref.FaceID)
+
+		f := finding{faceID: ref.FaceID, imageID: ref.ImageID}
+		switch {
+		case !hasFile:
+			f.kind = kindNoFile
+		case !ref.InRelations:
+			f.kind = kindNoRelation
+		case !ref.InNames:
+			f.kind = kindNoName
+		default:
+			continue
+		}
+		findings = append(findings, f)
+	}
+
+	now := time.Now()
+	for _, obj := range objects {
+		if _, ok := files[obj.Key]; !ok {
+			continue
+		}
+		if now.Sub(obj.ModTime) < minAge {
+			log.Printf("skip %s: saved %s ago", obj.Key, now.Sub(obj.ModTime).Round(time.Second))
+			continue
+		}
+		findings = append(findings, finding{kind: kindNoRows, faceID: obj.Key})
+	}
+
+	return findings, nil
+}
+
+// derivedSource возвращает бакет и имя исходного изображения варианта api_gw
+func derivedSource(key string) (string, string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(key, derivedPrefix), "/", 3)
+	if len(parts) != 3 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+func repair(ctx context.Context, repo *repository.YDB, faces storage.BlobStore, f finding) error {
+	switch f.kind {
+	case kindNoFile:
+		return repo.DeleteFace(ctx, f.faceID)
+	case kindNoRelation:
+		if err := repo.DeleteFace(ctx, f.faceID); err != nil {
+			return err
+		}
+		return faces.Delete(ctx, f.faceID)
+	case kindNoName:
+		// строка relations уже есть и не изменится, InsertFace добавит только names
+		return repo.InsertFace(ctx, repository.Face{FaceID: f.faceID}, f.imageID)
+	case kindNoRows:
+		return faces.Delete(ctx, f.faceID)
+	case kindStale:
+		return faces.Delete(ctx, f.key)
+	default:
+		return fmt.Errorf("unknown finding %s", f.kind)
+	}
+}
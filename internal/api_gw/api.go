@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"2hw/repository"
+	"2hw/signurl"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+	maxNameLength   = 100
+)
+
+// Face - лицо в ответах REST API
+type Face struct {
+	ID   string `json:"id"`
+	Name string `json:"name,omitempty" doc:"Имя, если оно есть"`
+	// AutoNamed - имя подобрано по дескриптору и ещё не подтверждено пользователем
+	AutoNamed bool   `json:"auto_named" doc:"Имя подобрано автоматически и не подтверждено"`
+	ImageID   string `json:"image_id,omitempty" doc:"Исходное фото"`
+	URL       string `json:"url" doc:"Подписанная ссылка на вырезанное лицо, относительная, если у функции нет API_GW_URL"`
+	// Detection нет у лиц, сохранённых до того, как face_cut стал записывать результат детектора
+	Detection *Detection `json:"detection,omitempty"`
+}
+
+// Detection - результат детектора, координаты в пикселях исходного фото
+type Detection struct {
+	X           int              `json:"x"`
+	Y           int              `json:"y"`
+	Width       int              `json:"width"`
+	Height      int              `json:"height"`
+	ImageWidth  int              `json:"image_width"`
+	ImageHeight int              `json:"image_height"`
+	Provider    string           `json:"provider,omitempty" doc:"Детектор: amazon, google, local, ..."`
+	Confidence  float64          `json:"confidence,omitempty" doc:"Уверенность детектора, если он её отдаёт"`
+	Landmarks   map[string]Point `json:"landmarks,omitempty" doc:"Точки лица, если детектор их отдаёт"`
+	CreatedAt   *time.Time       `json:"created_at,omitempty"`
+}
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+type FaceList struct {
+	Faces []Face `json:"faces"`
+	Next  string `json:"next,omitempty" doc:"Значение after для следующей страницы"`
+}
+
+type Image struct {
+	ID  string `json:"id"`
+	URL string `json:"url" doc:"Подписанная ссылка на фото, относительная, если у функции нет API_GW_URL"`
+}
+
+type ImageList struct {
+	Name   string  `json:"name"`
+	Images []Image `json:"images"`
+}
+
+type FaceImageList struct {
+	ImageID string `json:"image_id"`
+	Faces   []Face `json:"faces"`
+}
+
+type SetNameRequest struct {
+	Name string `json:"name" doc:"Новое имя, от 1 до 100 символов"`
+}
+
+type Error struct {
+	Error string `json:"error"`
+}
+
+var fileQuery = []queryParam{
+	{Name: "face", Type: "string", Description: "Имя файла в бакете faces"},
+	{Name: "image", Type: "string", Description: "Имя файла в бакете images"},
+	{Name: "w", Type: "integer", Description: "Максимальная ширина", Maximum: intPtr(maxDimension)},
+	{Name: "h", Type: "integer", Description: "Максимальная высота", Maximum: intPtr(maxDimension)},
+	{Name: "fit", Type: "string", Description: "Вписать или заполнить рамку w×h", Enum: []string{fitContain, fitCover}},
+	{Name: "q", Type: "integer", Description: "Качество JPEG", Minimum: intPtr(1), Maximum: intPtr(100)},
+	{Name: "format", Type: "string", Description: "Формат ответа", Enum: []string{formatJPEG, formatPNG}},
+	{Name: signurl.ParamExpires, Type: "integer", Description: "Срок действия ссылки, unix time", Required: true},
+	{Name: signurl.ParamSignature, Type: "string", Description: "Подпись ссылки", Required: true},
+}
+
+var pageQuery = []queryParam{
+	{Name: "named", Type: "boolean", Description: "true - только лица с подтверждённым именем, false - только без него"},
+	{Name: "after", Type: "string", Description: "id последнего лица предыдущей страницы"},
+	{Name: "limit", Type: "integer", Description: "Размер страницы", Minimum: intPtr(1), Maximum: intPtr(maxPageSize)},
+}
+
+// routes заполняется в init: обработчик /openapi.json сам читает routes
+var routes []route
+
+func init() {
+	routes = []route{
+		{Method: http.MethodGet, Pattern: "/", Summary: "Файл из бакета по подписанной ссылке",
+			Query: fileQuery, Binary: true, Public: true,
+			Errors: map[int]string{
+				http.StatusNotModified:          "Not modified since If-None-Match / If-Modified-Since",
+				http.StatusBadRequest:           "Bad resize parameters",
+				http.StatusForbidden:            "Missing, invalid or expired signature",
+				http.StatusNotFound:             "File not found",
+				http.StatusUnsupportedMediaType: "Image format can not be resized",
+				http.StatusUnprocessableEntity:  "Image is too large to be resized",
+			},
+			Handle: func(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+				return serveFile(ctx, req.event)
+			}},
+		{Method: http.MethodGet, Pattern: "/faces", Summary: "Список лиц",
+			Query: pageQuery, Response: FaceList{}, Handle: listFaces,
+			Errors: map[int]string{http.StatusBadRequest: "Bad filter or page parameters"}},
+		{Method: http.MethodGet, Pattern: "/faces/{id}", Summary: "Лицо",
+			Response: Face{}, Handle: getFace,
+			Errors: map[int]string{http.StatusNotFound: "Face not found"}},
+		{Method: http.MethodPatch, Pattern: "/faces/{id}", Summary: "Задать имя лица",
+			Request: SetNameRequest{}, Response: Face{}, Handle: setFaceName,
+			Errors: map[int]string{http.StatusBadRequest: "Bad name", http.StatusNotFound: "Face not found"}},
+		{Method: http.MethodGet, Pattern: "/people/{name}/images", Summary: "Фото, на которых есть человек",
+			Response: ImageList{}, Handle: personImages,
+			Errors: map[int]string{http.StatusNotFound: "Nobody with this name"}},
+		{Method: http.MethodGet, Pattern: "/images/{id}/faces", Summary: "Лица на фото",
+			Response: FaceImageList{}, Handle: imageFaces,
+			Errors: map[int]string{http.StatusNotFound: "Bad image id"}},
+		{Method: http.MethodGet, Pattern: "/openapi.json", Summary: "Эта спецификация",
+			Public: true, Handle: serveOpenAPI},
+	}
+	routes = append(routes, galleryRoutes()...)
+}
+
+func listFaces(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	filter := repository.FaceFilter{
+		After: req.query("after"),
+		Limit: defaultPageSize,
+	}
+
+	if v := req.query("named"); v != "" {
+		named, err := strconv.ParseBool(v)
+		if err != nil {
+			return errorResponse(http.StatusBadRequest, "named must be true or false"), nil
+		}
+		filter.Named = &named
+	}
+
+	if v := req.query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			return errorResponse(http.StatusBadRequest, fmt.Sprintf("limit must be from 1 to %d", maxPageSize)), nil
+		}
+		filter.Limit = limit
+	}
+
+	pageSize := filter.Limit
+	// лишнее лицо показывает, что есть следующая страница
+	filter.Limit++
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		faces, err := repo.ListFaces(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list faces: %w", err)
+		}
+
+		list := FaceList{Faces: []Face{}}
+		if len(faces) > pageSize {
+			faces = faces[:pageSize]
+			list.Next = faces[pageSize-1].FaceID
+		}
+
+		for _, f := range faces {
+			face, err := faceDTO(f)
+			if err != nil {
+				return nil, err
+			}
+			list.Faces = append(list.Faces, face)
+		}
+
+		return jsonResponse(http.StatusOK, list)
+	})
+}
+
+func getFace(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	faceID := req.params["id"]
+	if !faceIDPattern.MatchString(faceID) {
+		return errorResponse(http.StatusNotFound, "face not found"), nil
+	}
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		return faceResponse(ctx, repo, faceID)
+	})
+}
+
+func setFaceName(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	faceID := req.params["id"]
+	if !faceIDPattern.MatchString(faceID) {
+		return errorResponse(http.StatusNotFound, "face not found"), nil
+	}
+
+	var body SetNameRequest
+	if err := decodeBody(req.event, &body); err != nil {
+		return errorResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	name := strings.TrimSpace(body.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return errorResponse(http.StatusBadRequest, fmt.Sprintf("name must be from 1 to %d characters", maxNameLength)), nil
+	}
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		// NameFace создаёт строку для неизвестного лица, поэтому сначала проверяется, что оно есть
+		if _, err := repo.GetFace(ctx, faceID); err != nil {
+			return repositoryError(err, "face not found")
+		}
+
+		if err := repo.NameFace(ctx, faceID, name); err != nil {
+			return nil, fmt.Errorf("failed to name face %s: %w", faceID, err)
+		}
+
+		return faceResponse(ctx, repo, faceID)
+	})
+}
+
+func personImages(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	name := req.params["name"]
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		ids, err := repo.FindImagesByName(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find images of %s: %w", name, err)
+		}
+
+		list := ImageList{Name: name, Images: []Image{}}
+		seen := map[string]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			link, err := fileURL(url.Values{"image": {id}})
+			if err != nil {
+				return nil, err
+			}
+			list.Images = append(list.Images, Image{ID: id, URL: link})
+		}
+
+		if len(list.Images) == 0 {
+			return errorResponse(http.StatusNotFound, "person not found"), nil
+		}
+
+		return jsonResponse(http.StatusOK, list)
+	})
+}
+
+func imageFaces(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	imageID := req.params["id"]
+	if !imageIDPattern.MatchString(imageID) {
+		return errorResponse(http.StatusNotFound, "image not found"), nil
+	}
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		faces, err := repo.ImageFaces(ctx, imageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list faces of %s: %w", imageID, err)
+		}
+
+		list := FaceImageList{ImageID: imageID, Faces: []Face{}}
+		for _, f := range faces {
+			face, err := faceDTO(f)
+			if err != nil {
+				return nil, err
+			}
+			list.Faces = append(list.Faces, face)
+		}
+
+		return jsonResponse(http.StatusOK, list)
+	})
+}
+
+func withRepository(ctx context.Context, fn func(repo repository.Repository) (*APIGatewayResponse, error)) (*APIGatewayResponse, error) {
+	repo, closeRepo, err := repository.Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer closeRepo()
+
+	return fn(repo)
+}
+
+func faceResponse(ctx context.Context, repo repository.Repository, faceID string) (*APIGatewayResponse, error) {
+	f, err := repo.GetFace(ctx, faceID)
+	if err != nil {
+		return repositoryError(err, "face not found")
+	}
+
+	face, err := faceDTO(f)
+	if err != nil {
+		return nil, err
+	}
+
+	return jsonResponse(http.StatusOK, face)
+}
+
+func repositoryError(err error, notFound string) (*APIGatewayResponse, error) {
+	if errors.Is(err, repository.ErrNotFound) {
+		return errorResponse(http.StatusNotFound, notFound), nil
+	}
+
+	return nil, err
+}
+
+func faceDTO(f repository.Face) (Face, error) {
+	link, err := fileURL(url.Values{"face": {f.FaceID}})
+	if err != nil {
+		return Face{}, err
+	}
+
+	return Face{
+		ID:        f.FaceID,
+		Name:      f.FaceName,
+		AutoNamed: f.AutoNamed,
+		ImageID:   f.ImageID,
+		URL:       link,
+		Detection: detectionDTO(f.Detection),
+	}, nil
+}
+
+func detectionDTO(det *repository.Detection) *Detection {
+	if det == nil {
+		return nil
+	}
+
+	dto := &Detection{
+		X:           det.X,
+		Y:           det.Y,
+		Width:       det.Width,
+		Height:      det.Height,
+		ImageWidth:  det.ImageWidth,
+		ImageHeight: det.ImageHeight,
+		Provider:    det.Provider,
+		Confidence:  det.Confidence,
+	}
+	if !det.CreatedAt.IsZero() {
+		createdAt := det.CreatedAt
+		dto.CreatedAt = &createdAt
+	}
+	if len(det.Landmarks) > 0 {
+		dto.Landmarks = make(map[string]Point, len(det.Landmarks))
+		for name, p := range det.Landmarks {
+			dto.Landmarks[name] = Point{X: p.X, Y: p.Y}
+		}
+	}
+
+	return dto
+}
+
+// fileURL - подписанная ссылка на файл на этом же шлюзе. Домен берётся только из API_GW_URL:
+// Host задаёт клиент, и подписанная по нему ссылка могла бы вести на чужой сайт. main.tf не может
+// передать функции адрес шлюза, который сам от неё зависит, поэтому без API_GW_URL ссылка
+// относительная и открывается на том же домене, на который пришёл запрос
+func fileURL(params url.Values) (string, error) {
+	domain := os.Getenv("API_GW_URL")
+	if domain == "" {
+		return fileLink(params)
+	}
+
+	s, err := signurl.FromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	return s.URL(domain, params), nil
+}
+
+// readBody возвращает тело запроса; API Gateway присылает бинарные и form тела в base64
+func readBody(event *APIGatewayRequest) ([]byte, error) {
+	if !event.IsBase64Encoded {
+		return []byte(event.Body), nil
+	}
+
+	body, err := base64.StdEncoding.DecodeString(event.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid body encoding: %w", err)
+	}
+
+	return body, nil
+}
+
+func decodeBody(event *APIGatewayRequest, v interface{}) error {
+	body, err := readBody(event)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("invalid JSON body: %w", err)
+	}
+
+	return nil
+}
+
+func intPtr(v int) *int {
+	return &v
+}
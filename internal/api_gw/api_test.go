@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFileURLDomain(t *testing.T) {
+	tests := []struct {
+		name string
+		env  string
+		want string
+	}{
+		{"env", "gw.example.com", "https://gw.example.com/?"},
+		{"relative without env", "", "/?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("URL_SIGNING_KEY", "key")
+			t.Setenv("API_GW_URL", tt.env)
+
+			got, err := fileURL(url.Values{"face": {"f.jpg"}})
+			if err != nil {
+				t.Fatalf("fileURL() error = %v", err)
+			}
+			if !strings.HasPrefix(got, tt.want) {
+				t.Errorf("fileURL() = %q, want prefix %q", got, tt.want)
+			}
+		})
+	}
+}
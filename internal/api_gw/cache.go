@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"2hw/storage"
+)
+
+// etag строится по времени изменения и размеру объекта, как у nginx:
+// его можно сравнить с If-None-Match, не читая сам файл. У вариантов изображения
+// к нему добавляется variant
+func etag(info storage.ObjectInfo, variant string) string {
+	if variant == "" {
+		return fmt.Sprintf(`"%x-%x"`, info.ModTime.Unix(), info.Size)
+	}
+
+	return fmt.Sprintf(`"%x-%x-%s"`, info.ModTime.Unix(), info.Size, variant)
+}
+
+// derivedKey - ключ варианта в бакете faces. В него входит версия оригинала,
+// поэтому при замене оригинала старые варианты просто перестают использоваться
+func derivedKey(bucket, name string, info storage.ObjectInfo, p *resizeParams) string {
+	return path.Join(derivedPrefix, bucket, name, fmt.Sprintf("%x-%x-%s", info.ModTime.Unix(), info.Size, p.variant()))
+}
+
+// header ищет заголовок без учёта регистра: API Gateway не нормализует имена
+func header(event *APIGatewayRequest, name string) string {
+	for k, v := range event.Headers {
+		if strings.EqualFold(k, name) {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// notModified проверяет условные заголовки запроса по правилам RFC 9110:
+// If-Modified-Since учитывается, только если нет If-None-Match
+func notModified(event *APIGatewayRequest, tag string, modTime time.Time) bool {
+	if event.HTTPMethod != http.MethodGet && event.HTTPMethod != http.MethodHead {
+		return false
+	}
+
+	if inm := header(event, "If-None-Match"); inm != "" {
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == tag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	ims := header(event, "If-Modified-Since")
+	if ims == "" || modTime.IsZero() {
+		return false
+	}
+
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+
+	return !modTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// heicBrands - major brand в ftyp-заголовке HEIF-контейнеров (HEIC с камер iPhone и т.п.)
+var heicBrands = map[string]string{
+	"heic": "image/heic",
+	"heix": "image/heic",
+	"heim": "image/heic",
+	"heis": "image/heic",
+	"hevc": "image/heic-sequence",
+	"hevx": "image/heic-sequence",
+	"mif1": "image/heif",
+	"msf1": "image/heif-sequence",
+}
+
+// sniffContentType определяет тип изображения по содержимому, а не по имени файла.
+// http.DetectContentType знает JPEG, PNG, GIF, WebP и BMP; HEIC проверяется отдельно
+func sniffContentType(data []byte) string {
+	if len(data) >= 12 && bytes.Equal(data[4:8], []byte("ftyp")) {
+		if ct, ok := heicBrands[string(data[8:12])]; ok {
+			return ct
+		}
+	}
+
+	return http.DetectContentType(data)
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"2hw/repository"
+	"2hw/signurl"
+)
+
+const (
+	galleryRoot  = "/gallery"
+	galleryLogin = "/gallery/login"
+
+	// tokenCookie хранит API_TOKEN после входа в галерею
+	tokenCookie       = "api_token"
+	tokenCookieMaxAge = 30 * 24 * 60 * 60
+
+	galleryPageSize = 48
+	faceThumbSize   = "160"
+	imageThumbSize  = "320"
+	imageViewSize   = "1280"
+
+	// nameFieldPrefix - поля формы name.<faceID> с именем для отдельного лица
+	nameFieldPrefix = "name."
+)
+
+//go:embed templates/*.html
+var templatesFS embed.FS
+
+var galleryTemplates = template.Must(template.New("gallery").
+	Funcs(template.FuncMap{"pathEscape": url.PathEscape}).
+	ParseFS(templatesFS, "templates/*.html"))
+
+type galleryFace struct {
+	ID        string
+	Name      string
+	AutoNamed bool
+	ImageID   string
+	Thumb     string
+	// Box - рамка лица на фото в процентах от его размеров, nil - границы не сохранены
+	Box *galleryBox
+}
+
+type galleryBox struct {
+	Left   float64
+	Top    float64
+	Width  float64
+	Height float64
+}
+
+type galleryImage struct {
+	ID    string
+	Thumb string
+}
+
+// galleryPage - данные для всех шаблонов галереи, каждая страница заполняет свою часть
+type galleryPage struct {
+	Title string
+	Error string
+
+	Faces []galleryFace
+	// Names - подсказки для полей имени и список людей
+	Names  []string
+	After  string
+	Next   string
+	Person string
+	Images []galleryImage
+	Image  *galleryImage
+}
+
+func galleryRoutes() []route {
+	return []route{
+		{Method: http.MethodGet, Pattern: galleryRoot, Summary: "Галерея: лица без имени",
+			Query: []queryParam{{Name: "after", Type: "string", Description: "id последнего лица предыдущей страницы"}},
+			HTML:  true, Handle: galleryFaces},
+		{Method: http.MethodPost, Pattern: galleryRoot + "/faces", Summary: "Галерея: присвоить имена",
+			HTML: true, Handle: galleryNameFaces,
+			Errors: map[int]string{http.StatusSeeOther: "Back to the gallery", http.StatusBadRequest: "Bad form"}},
+		{Method: http.MethodGet, Pattern: galleryRoot + "/people", Summary: "Галерея: люди",
+			HTML: true, Handle: galleryPeople},
+		{Method: http.MethodGet, Pattern: galleryRoot + "/people/{name}", Summary: "Галерея: фото человека",
+			HTML: true, Handle: galleryPerson},
+		{Method: http.MethodGet, Pattern: galleryRoot + "/images/{id}", Summary: "Галерея: фото и лица на нём",
+			HTML: true, Handle: galleryImageView,
+			Errors: map[int]string{http.StatusNotFound: "Bad image id"}},
+		{Method: http.MethodGet, Pattern: galleryLogin, Summary: "Галерея: вход",
+			HTML: true, Public: true, Handle: galleryLoginForm},
+		{Method: http.MethodPost, Pattern: galleryLogin, Summary: "Галерея: вход по API_TOKEN",
+			HTML: true, Public: true, Handle: galleryLoginSubmit,
+			Errors: map[int]string{http.StatusSeeOther: "Logged in", http.StatusForbidden: "Wrong token"}},
+	}
+}
+
+func galleryFaces(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	unnamed := false
+	filter := repository.FaceFilter{
+		Named: &unnamed,
+		After: req.query("after"),
+		// лишнее лицо показывает, что есть следующая страница
+		Limit: galleryPageSize + 1,
+	}
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		faces, err := repo.ListFaces(ctx, filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list faces: %w", err)
+		}
+
+		names, err := repo.ListNames(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list names: %w", err)
+		}
+
+		page := &galleryPage{
+			Title: "Лица без имени",
+			Names: names,
+			After: filter.After,
+		}
+		if len(faces) > galleryPageSize {
+			faces = faces[:galleryPageSize]
+			page.Next = faces[galleryPageSize-1].FaceID
+		}
+
+		page.Faces, err = galleryFacesOf(faces)
+		if err != nil {
+			return nil, err
+		}
+
+		return renderPage(http.StatusOK, "faces", page)
+	})
+}
+
+// galleryNameFaces присваивает общее имя отмеченным лицам и имена из полей name.<faceID>
+func galleryNameFaces(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	form, err := readForm(req.event)
+	if err != nil {
+		return textResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	names := map[string]string{}
+	if common := strings.TrimSpace(form.Get("name")); common != "" {
+		for _, faceID := range form["face"] {
+			names[faceID] = common
+		}
+	}
+	for field, values := range form {
+		faceID, ok := strings.CutPrefix(field, nameFieldPrefix)
+		if !ok {
+			continue
+		}
+		if name := strings.TrimSpace(values[0]); name != "" {
+			names[faceID] = name
+		}
+	}
+
+	for faceID, name := range names {
+		if !faceIDPattern.MatchString(faceID) {
+			return textResponse(http.StatusBadRequest, "bad face id "+faceID), nil
+		}
+		if utf8.RuneCountInString(name) > maxNameLength {
+			return textResponse(http.StatusBadRequest, fmt.Sprintf("name must be at most %d characters", maxNameLength)), nil
+		}
+	}
+
+	back := galleryRoot
+	if after := form.Get("after"); after != "" {
+		back += "?" + url.Values{"after": {after}}.Encode()
+	}
+
+	if len(names) == 0 {
+		return redirect(back), nil
+	}
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		for faceID, name := range names {
+			// NameFace создаёт строку для неизвестного лица, удалённые за это время лица пропускаются
+			_, err := repo.GetFace(ctx, faceID)
+			if errors.Is(err, repository.ErrNotFound) {
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("failed to get face %s: %w", faceID, err)
+			}
+
+			if err := repo.NameFace(ctx, faceID, name); err != nil {
+				return nil, fmt.Errorf("failed to name face %s: %w", faceID, err)
+			}
+		}
+
+		return redirect(back), nil
+	})
+}
+
+func galleryPeople(ctx context.Context, _ *apiRequest) (*APIGatewayResponse, error) {
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		names, err := repo.ListNames(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list names: %w", err)
+		}
+		sort.Strings(names)
+
+		return renderPage(http.StatusOK, "people", &galleryPage{
+			Title: "Люди",
+			Names: names,
+		})
+	})
+}
+
+func galleryPerson(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	name := req.params["name"]
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		ids, err := repo.FindImagesByName(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to find images of %s: %w", name, err)
+		}
+
+		page := &galleryPage{
+			Title:  name,
+			Person: name,
+		}
+
+		seen := map[string]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+
+			thumb, err := fileLink(url.Values{"image": {id}, "w": {imageThumbSize}, "h": {imageThumbSize}})
+			if err != nil {
+				return nil, err
+			}
+			page.Images = append(page.Images, galleryImage{ID: id, Thumb: thumb})
+		}
+
+		return renderPage(http.StatusOK, "person", page)
+	})
+}
+
+func galleryImageView(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	imageID := req.params["id"]
+	if !imageIDPattern.MatchString(imageID) {
+		return textResponse(http.StatusNotFound, "image not found"), nil
+	}
+
+	return withRepository(ctx, func(repo repository.Repository) (*APIGatewayResponse, error) {
+		faces, err := repo.ImageFaces(ctx, imageID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list faces of %s: %w", imageID, err)
+		}
+
+		view, err := fileLink(url.Values{"image": {imageID}, "w": {imageViewSize}, "h": {imageViewSize}})
+		if err != nil {
+			return nil, err
+		}
+
+		page := &galleryPage{
+			Title: imageID,
+			Image: &galleryImage{ID: imageID, Thumb: view},
+		}
+
+		page.Faces, err = galleryFacesOf(faces)
+		if err != nil {
+			return nil, err
+		}
+
+		return renderPage(http.StatusOK, "image", page)
+	})
+}
+
+func galleryLoginForm(_ context.Context, _ *apiRequest) (*APIGatewayResponse, error) {
+	return renderPage(http.StatusOK, "login", &galleryPage{Title: "Вход"})
+}
+
+func galleryLoginSubmit(_ context.Context, req *apiRequest) (*APIGatewayResponse, error) {
+	form, err := readForm(req.event)
+	if err != nil {
+		return textResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	token := os.Getenv("API_TOKEN")
+	got := form.Get("token")
+	if token == "" || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		return renderPage(http.StatusForbidden, "login", &galleryPage{
+			Title: "Вход",
+			Error: "Неверный токен",
+		})
+	}
+
+	// SameSite=Strict не даёт чужим сайтам отправлять формы галереи с этой cookie
+	c := &http.Cookie{
+		Name:     tokenCookie,
+		Value:    token,
+		Path:     galleryRoot,
+		MaxAge:   tokenCookieMaxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	}
+
+	resp := redirect(galleryRoot)
+	resp.Headers["Set-Cookie"] = c.String()
+
+	return resp, nil
+}
+
+func galleryFacesOf(faces []repository.Face) ([]galleryFace, error) {
+	result := make([]galleryFace, 0, len(faces))
+	for _, f := range faces {
+		thumb, err := fileLink(url.Values{
+			"face": {f.FaceID},
+			"w":    {faceThumbSize},
+			"h":    {faceThumbSize},
+			"fit":  {fitCover},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, galleryFace{
+			ID:        f.FaceID,
+			Name:      f.FaceName,
+			AutoNamed: f.AutoNamed,
+			ImageID:   f.ImageID,
+			Thumb:     thumb,
+			Box:       boxOf(f.Detection),
+		})
+	}
+
+	return result, nil
+}
+
+func boxOf(det *repository.Detection) *galleryBox {
+	if det == nil || det.ImageWidth <= 0 || det.ImageHeight <= 0 {
+		return nil
+	}
+
+	w := float64(det.ImageWidth) / 100
+	h := float64(det.ImageHeight) / 100
+
+	return &galleryBox{
+		Left:   float64(det.X) / w,
+		Top:    float64(det.Y) / h,
+		Width:  float64(det.Width) / w,
+		Height: float64(det.Height) / h,
+	}
+}
+
+// fileLink - подписанная ссылка на файл относительно корня шлюза, для страниц галереи
+func fileLink(params url.Values) (string, error) {
+	s, err := signurl.FromEnv()
+	if err != nil {
+		return "", err
+	}
+
+	return "/?" + s.Sign(params).Encode(), nil
+}
+
+func readForm(event *APIGatewayRequest) (url.Values, error) {
+	body, err := readBody(event)
+	if err != nil {
+		return nil, err
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("invalid form: %w", err)
+	}
+
+	return form, nil
+}
+
+func renderPage(status int, name string, page *galleryPage) (*APIGatewayResponse, error) {
+	var buf bytes.Buffer
+	if err := galleryTemplates.ExecuteTemplate(&buf, name, page); err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", name, err)
+	}
+
+	return htmlResponse(status, buf.Bytes()), nil
+}
@@ -2,10 +2,14 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
-	"path"
+	"strconv"
+
+	"2hw/signurl"
+	"2hw/storage"
 )
 
 // Структура запроса API Gateway v1
@@ -42,18 +46,40 @@ type APIGatewayResponse struct {
 	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
 }
 
-const (
-	facesDir  = "/function/storage/faces"
-	imagesDir = "/function/storage/images"
-)
+var stores = map[string]storage.BlobStore{}
+
+// getStore открывает хранилище name (storage.Images или storage.Faces) один раз на инстанс функции
+func getStore(ctx context.Context, name string) (storage.BlobStore, error) {
+	if store, ok := stores[name]; ok {
+		return store, nil
+	}
+
+	store, err := storage.FromEnv(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s storage: %w", name, err)
+	}
+	stores[name] = store
+
+	return store, nil
+}
 
 func Handler(ctx context.Context, event *APIGatewayRequest) (*APIGatewayResponse, error) {
+	rt, params := routeFor(event)
+	if rt == nil {
+		return errorResponse(http.StatusNotFound, "not found"), nil
+	}
+
+	return rt.serve(ctx, event, params)
+}
+
+// serveFile отдаёт файл из бакета faces или images по подписанной ссылке
+func serveFile(ctx context.Context, event *APIGatewayRequest) (*APIGatewayResponse, error) {
 	name := event.QueryStringParameters["face"]
-	dir := facesDir
+	bucket := storage.Faces
 
 	if name == "" {
 		name = event.QueryStringParameters["image"]
-		dir = imagesDir
+		bucket = storage.Images
 	}
 
 	if name == "" {
@@ -65,18 +91,131 @@ func Handler(ctx context.Context, event *APIGatewayRequest) (*APIGatewayResponse
 	// В журнале будет напечатано название HTTP-метода, с помощью которого осуществлен запрос, а также путь
 	fmt.Println(event.HTTPMethod, name)
 
-	fileBytes, err := ioutil.ReadFile(path.Join(dir, name))
+	if !validName(bucket, name) {
+		return &APIGatewayResponse{
+			StatusCode: http.StatusNotFound,
+		}, nil
+	}
+
+	signer, err := signurl.FromEnv()
 	if err != nil {
+		return nil, err
+	}
+
+	if err := signer.Verify(event.QueryStringParameters); err != nil {
+		return textResponse(http.StatusForbidden, err.Error()), nil
+	}
+
+	params, err := parseResize(event.QueryStringParameters)
+	if err != nil {
+		return textResponse(http.StatusBadRequest, err.Error()), nil
+	}
+
+	store, err := getStore(ctx, bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := store.Stat(ctx, name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return &APIGatewayResponse{
+			StatusCode: http.StatusNotFound,
+		}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat %s: %w", name, err)
+	}
+
+	variant := ""
+	if params != nil {
+		variant = params.variant()
+	}
+
+	tag := etag(info, variant)
+	headers := map[string]string{"ETag": tag}
+	if !info.ModTime.IsZero() {
+		headers["Last-Modified"] = info.ModTime.UTC().Format(http.TimeFormat)
+	}
+
+	if notModified(event, tag, info.ModTime) {
+		return &APIGatewayResponse{
+			StatusCode: http.StatusNotModified,
+			Headers:    headers,
+		}, nil
+	}
+
+	var fileBytes []byte
+	if params == nil {
+		fileBytes, err = store.Get(ctx, name)
+		headers["Content-Type"] = sniffContentType(fileBytes)
+	} else {
+		fileBytes, err = getVariant(ctx, store, bucket, name, info, params)
+		headers["Content-Type"] = params.contentType()
+	}
+	if errors.Is(err, storage.ErrNotFound) {
 		return &APIGatewayResponse{
 			StatusCode: http.StatusNotFound,
 		}, nil
 	}
+	if errors.Is(err, errUnsupported) {
+		return textResponse(http.StatusUnsupportedMediaType, err.Error()), nil
+	}
+	if errors.Is(err, errTooLarge) {
+		return textResponse(http.StatusUnprocessableEntity, err.Error()), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+
+	headers["Content-Length"] = strconv.Itoa(len(fileBytes))
+
+	if event.HTTPMethod == http.MethodHead {
+		return &APIGatewayResponse{
+			StatusCode: http.StatusOK,
+			Headers:    headers,
+		}, nil
+	}
 
 	// Тело ответа.
 	return &APIGatewayResponse{
 		StatusCode:      200,
 		Body:            fileBytes,
-		Headers:         map[string]string{"Content-type": "image/jpeg"},
+		Headers:         headers,
 		IsBase64Encoded: true,
 	}, nil
 }
+
+// getVariant отдаёт уменьшенную копию изображения из кэша в бакете faces или создаёт её
+func getVariant(ctx context.Context, store storage.BlobStore, bucket, name string, info storage.ObjectInfo, params *resizeParams) ([]byte, error) {
+	derived, err := getStore(ctx, storage.Faces)
+	if err != nil {
+		return nil, err
+	}
+
+	key := derivedKey(bucket, name, info, params)
+
+	data, err := derived.Get(ctx, key)
+	if err == nil {
+		return data, nil
+	}
+	if !errors.Is(err, storage.ErrNotFound) {
+		log.Printf("failed to read cached %s: %v", key, err)
+	}
+
+	orig, err := store.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = resize(orig, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// без кэша вариант просто пересоздастся в следующий раз
+	if err := derived.Put(ctx, key, data, params.contentType()); err != nil {
+		log.Printf("failed to cache %s: %v", key, err)
+	}
+
+	return data, nil
+}
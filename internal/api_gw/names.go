@@ -0,0 +1,22 @@
+package main
+
+import (
+	"regexp"
+
+	"2hw/storage"
+)
+
+var (
+	// faceIDPattern - имена лиц, которые сохраняет face_cut: UUIDv5 от ID фото и границ лица + ".jpg"
+	faceIDPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\.jpg$`)
+	// imageIDPattern - ключи объектов в корне бакета images: без каталогов и ведущих точек
+	imageIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,254}$`)
+)
+
+func validName(bucket, name string) bool {
+	if bucket == storage.Faces {
+		return faceIDPattern.MatchString(name)
+	}
+
+	return imageIDPattern.MatchString(name)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// openAPIIntegration - вызов этой функции из API Gateway. ${...} подставляет templatefile в main.tf
+var openAPIIntegration = map[string]interface{}{
+	"type":                   "cloud_functions",
+	"payload_format_version": "0.1",
+	"function_id":            "${function_id}",
+	"tag":                    "$latest",
+	"service_account_id":     "${service_account_id}",
+}
+
+// buildSpec строит OpenAPI 3.0 спецификацию по routes и Go-типам тел запросов и ответов.
+// integration добавляет к методам x-yc-apigateway-integration для спецификации шлюза
+func buildSpec(integration bool) map[string]interface{} {
+	schemas := map[string]interface{}{}
+	errorSchema := schemaFor(reflect.TypeOf(Error{}), schemas)
+
+	paths := map[string]interface{}{}
+	for _, rt := range routes {
+		op := map[string]interface{}{
+			"summary": rt.Summary,
+		}
+
+		var params []interface{}
+		for _, segment := range strings.Split(rt.Pattern, "/") {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				params = append(params, map[string]interface{}{
+					"name":     segment[1 : len(segment)-1],
+					"in":       "path",
+					"required": true,
+					"schema":   map[string]interface{}{"type": "string"},
+				})
+			}
+		}
+		for _, q := range rt.Query {
+			params = append(params, queryParamSpec(q))
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+
+		if rt.HTML && rt.Method == http.MethodPost {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{
+					"application/x-www-form-urlencoded": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "object"},
+					},
+				},
+			}
+		}
+		if rt.Request != nil {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaFor(reflect.TypeOf(rt.Request), schemas)),
+			}
+		}
+
+		responses := map[string]interface{}{}
+		switch {
+		case rt.Binary:
+			responses["200"] = map[string]interface{}{
+				"description": "File, Content-Type is detected from its contents",
+				"content": map[string]interface{}{
+					"image/*": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string", "format": "binary"},
+					},
+				},
+			}
+		case rt.HTML:
+			responses["200"] = map[string]interface{}{
+				"description": "HTML page",
+				"content": map[string]interface{}{
+					"text/html": map[string]interface{}{
+						"schema": map[string]interface{}{"type": "string"},
+					},
+				},
+			}
+		case rt.Response != nil:
+			responses["200"] = map[string]interface{}{
+				"description": "OK",
+				"content":     jsonContent(schemaFor(reflect.TypeOf(rt.Response), schemas)),
+			}
+		default:
+			responses["200"] = map[string]interface{}{"description": "OK"}
+		}
+
+		errors := map[int]string{}
+		for code, description := range rt.Errors {
+			errors[code] = description
+		}
+		if !rt.Public {
+			if rt.HTML {
+				errors[http.StatusSeeOther] = "Redirect to the login page"
+			} else {
+				errors[http.StatusUnauthorized] = "Missing or invalid API token"
+			}
+			op["security"] = []interface{}{
+				map[string]interface{}{"bearer": []string{}},
+				map[string]interface{}{"cookie": []string{}},
+			}
+		}
+		for code, description := range errors {
+			resp := map[string]interface{}{"description": description}
+			// у файлового метода и страниц ошибки без JSON тела, у JSON методов - Error
+			if !rt.Binary && !rt.HTML && code != http.StatusNotModified {
+				resp["content"] = jsonContent(errorSchema)
+			}
+			responses[strconv.Itoa(code)] = resp
+		}
+		op["responses"] = responses
+
+		if integration {
+			op["x-yc-apigateway-integration"] = openAPIIntegration
+		}
+
+		item, ok := paths[rt.Pattern].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[rt.Pattern] = item
+		}
+		item[strings.ToLower(rt.Method)] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":   "Face API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas,
+			"securitySchemes": map[string]interface{}{
+				"bearer": map[string]interface{}{
+					"type":   "http",
+					"scheme": "bearer",
+				},
+				"cookie": map[string]interface{}{
+					"type": "apiKey",
+					"in":   "cookie",
+					"name": tokenCookie,
+				},
+			},
+		},
+	}
+}
+
+func queryParamSpec(q queryParam) map[string]interface{} {
+	schema := map[string]interface{}{"type": q.Type}
+	if len(q.Enum) > 0 {
+		schema["enum"] = q.Enum
+	}
+	if q.Minimum != nil {
+		schema["minimum"] = *q.Minimum
+	}
+	if q.Maximum != nil {
+		schema["maximum"] = *q.Maximum
+	}
+
+	spec := map[string]interface{}{
+		"name":     q.Name,
+		"in":       "query",
+		"required": q.Required,
+		"schema":   schema,
+	}
+	if q.Description != "" {
+		spec["description"] = q.Description
+	}
+
+	return spec
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// schemaFor описывает Go-тип JSON-схемой. Структуры попадают в components/schemas под своим именем;
+// поля без omitempty считаются обязательными, тег doc становится description
+func schemaFor(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return schemaFor(t.Elem(), schemas)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":  "array",
+			"items": schemaFor(t.Elem(), schemas),
+		}
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": schemaFor(t.Elem(), schemas),
+		}
+	case reflect.Struct:
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+		if _, ok := schemas[t.Name()]; ok {
+			return ref
+		}
+		// заглушка до обхода полей, чтобы рекурсивные типы не зацикливались
+		schemas[t.Name()] = nil
+
+		properties := map[string]interface{}{}
+		var required []string
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = field.Name
+			}
+
+			prop := schemaFor(field.Type, schemas)
+			if doc := field.Tag.Get("doc"); doc != "" {
+				// рядом с $ref остальные ключи игнорируются, поэтому описание добавляется только к простым типам
+				if _, isRef := prop["$ref"]; !isRef {
+					prop["description"] = doc
+				}
+			}
+			properties[name] = prop
+
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		sort.Strings(required)
+
+		schema := map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+		}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		schemas[t.Name()] = schema
+
+		return ref
+	}
+
+	return map[string]interface{}{}
+}
+
+// serveOpenAPI отдаёт спецификацию без расширений API Gateway
+func serveOpenAPI(_ context.Context, _ *apiRequest) (*APIGatewayResponse, error) {
+	return jsonResponse(http.StatusOK, buildSpec(false))
+}
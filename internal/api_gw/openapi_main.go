@@ -0,0 +1,22 @@
+//go:build openapi
+
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+)
+
+// Спецификация шлюза для main.tf генерируется из routes:
+//
+//	go run -tags openapi . > openapi.json
+func main() {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(buildSpec(true)); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/png"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	_ "golang.org/x/image/webp"
+)
+
+const (
+	fitContain = "contain"
+	fitCover   = "cover"
+
+	formatJPEG = "jpeg"
+	formatPNG  = "png"
+
+	// maxDimension не даёт запросить изображение, на которое уйдёт вся память функции
+	maxDimension   = 2048
+	defaultQuality = 85
+	// maxSourcePixels - предел исходного изображения: 4 байта на пиксель после декодирования,
+	// а у функции 128 МБ. Фото телефона в 12 Мп проходит
+	maxSourcePixels = 12 << 20
+
+	// derivedPrefix - префикс в бакете faces для вариантов изображений: у бакета нет триггера,
+	// а cmd/reconcile удаляет варианты, исходника которых уже нет
+	derivedPrefix = "derived"
+)
+
+var (
+	errBadParams   = errors.New("bad resize params")
+	errUnsupported = errors.New("image format can not be resized")
+	errTooLarge    = errors.New("image is too large to be resized")
+)
+
+// resizeParams - параметры w, h, fit, q и format запроса изображения
+type resizeParams struct {
+	Width   int
+	Height  int
+	Fit     string
+	Quality int
+	Format  string
+}
+
+// parseResize возвращает nil, если изображение нужно отдать как есть
+func parseResize(query map[string]string) (*resizeParams, error) {
+	if query["w"] == "" && query["h"] == "" && query["fit"] == "" && query["q"] == "" && query["format"] == "" {
+		return nil, nil
+	}
+
+	p := &resizeParams{
+		Fit:     fitContain,
+		Quality: defaultQuality,
+		Format:  formatJPEG,
+	}
+
+	var err error
+	if p.Width, err = parseDimension(query["w"]); err != nil {
+		return nil, fmt.Errorf("%w: w: %v", errBadParams, err)
+	}
+	if p.Height, err = parseDimension(query["h"]); err != nil {
+		return nil, fmt.Errorf("%w: h: %v", errBadParams, err)
+	}
+
+	if v := query["fit"]; v != "" {
+		if v != fitContain && v != fitCover {
+			return nil, fmt.Errorf("%w: fit must be %s or %s", errBadParams, fitContain, fitCover)
+		}
+		p.Fit = v
+	}
+
+	if v := query["q"]; v != "" {
+		q, err := strconv.Atoi(v)
+		if err != nil || q < 1 || q > 100 {
+			return nil, fmt.Errorf("%w: q must be 1..100", errBadParams)
+		}
+		p.Quality = q
+	}
+
+	switch v := query["format"]; v {
+	case "", formatJPEG, "jpg":
+	case formatPNG:
+		p.Format = formatPNG
+	default:
+		return nil, fmt.Errorf("%w: format must be %s or %s", errBadParams, formatJPEG, formatPNG)
+	}
+
+	return p, nil
+}
+
+func parseDimension(v string) (int, error) {
+	if v == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return 0, errors.New("must be a positive number")
+	}
+	if n > maxDimension {
+		return 0, fmt.Errorf("must not exceed %d", maxDimension)
+	}
+
+	return n, nil
+}
+
+// variant - имя варианта для ETag и ключа в derivedPrefix
+func (p *resizeParams) variant() string {
+	return fmt.Sprintf("w%d-h%d-%s-q%d.%s", p.Width, p.Height, p.Fit, p.Quality, p.Format)
+}
+
+func (p *resizeParams) contentType() string {
+	if p.Format == formatPNG {
+		return "image/png"
+	}
+
+	return "image/jpeg"
+}
+
+// resize уменьшает изображение и перекодирует его в p.Format; увеличение не делается.
+// Размер исходника проверяется по заголовку до декодирования
+func resize(data []byte, p *resizeParams) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnsupported, err)
+	}
+	if cfg.Width*cfg.Height > maxSourcePixels {
+		return nil, fmt.Errorf("%w: %dx%d is more than %d pixels", errTooLarge, cfg.Width, cfg.Height, maxSourcePixels)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnsupported, err)
+	}
+
+	b := img.Bounds()
+	w := min(p.Width, b.Dx())
+	h := min(p.Height, b.Dy())
+
+	var out image.Image
+	switch {
+	case w > 0 && h > 0 && p.Fit == fitCover:
+		out = imaging.Fill(img, w, h, imaging.Center, imaging.Lanczos)
+	case w > 0 && h > 0:
+		out = imaging.Fit(img, w, h, imaging.Lanczos)
+	case w > 0 || h > 0:
+		out = imaging.Resize(img, w, h, imaging.Lanczos)
+	default:
+		// только q или format
+		out = img
+	}
+
+	buf := &bytes.Buffer{}
+	if p.Format == formatPNG {
+		err = imaging.Encode(buf, out, imaging.PNG)
+	} else {
+		err = imaging.Encode(buf, out, imaging.JPEG, imaging.JPEGQuality(p.Quality))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, image.NewGray(image.Rect(0, 0, w, h))); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestResizeSourceLimit(t *testing.T) {
+	p := &resizeParams{Width: 100, Fit: fitContain, Quality: defaultQuality, Format: formatJPEG}
+
+	if _, err := resize(encodePNG(t, 400, 300), p); err != nil {
+		t.Fatalf("resize() error = %v", err)
+	}
+
+	if _, err := resize(encodePNG(t, 5000, 3000), p); !errors.Is(err, errTooLarge) {
+		t.Errorf("resize() error = %v, want %v", err, errTooLarge)
+	}
+}
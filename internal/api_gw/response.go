@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Body у APIGatewayResponse - []byte и в JSON кодируется в base64,
+// поэтому IsBase64Encoded выставляется для любого ответа с телом
+
+func textResponse(status int, text string) *APIGatewayResponse {
+	return &APIGatewayResponse{
+		StatusCode:      status,
+		Headers:         map[string]string{"Content-Type": "text/plain; charset=utf-8"},
+		Body:            []byte(text),
+		IsBase64Encoded: true,
+	}
+}
+
+func jsonResponse(status int, v interface{}) (*APIGatewayResponse, error) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return &APIGatewayResponse{
+		StatusCode:      status,
+		Headers:         map[string]string{"Content-Type": "application/json; charset=utf-8"},
+		Body:            body,
+		IsBase64Encoded: true,
+	}, nil
+}
+
+func errorResponse(status int, msg string) *APIGatewayResponse {
+	resp, err := jsonResponse(status, &Error{Error: msg})
+	if err != nil {
+		return textResponse(http.StatusInternalServerError, err.Error())
+	}
+
+	return resp
+}
+
+func htmlResponse(status int, body []byte) *APIGatewayResponse {
+	return &APIGatewayResponse{
+		StatusCode:      status,
+		Headers:         map[string]string{"Content-Type": "text/html; charset=utf-8"},
+		Body:            body,
+		IsBase64Encoded: true,
+	}
+}
+
+// redirect - 303: после POST формы браузер переходит на location методом GET
+func redirect(location string) *APIGatewayResponse {
+	return &APIGatewayResponse{
+		StatusCode: http.StatusSeeOther,
+		Headers:    map[string]string{"Location": location},
+	}
+}
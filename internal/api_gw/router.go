@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// queryParam - параметр строки запроса, попадает в OpenAPI спецификацию
+type queryParam struct {
+	Name        string
+	Type        string
+	Description string
+	Required    bool
+	Enum        []string
+	Minimum     *int
+	Maximum     *int
+}
+
+// route - метод API. По этой же таблице строится OpenAPI спецификация (см. openapi.go)
+type route struct {
+	Method  string
+	Pattern string
+	Summary string
+	Query   []queryParam
+	// Request и Response - значения Go-типов тела запроса и ответа, nil - тела нет
+	Request  interface{}
+	Response interface{}
+	// Binary - ответом является файл, а не JSON
+	Binary bool
+	// HTML - страница галереи: ответ в text/html, тело POST - форма, без токена - переход на вход
+	HTML bool
+	// Public - метод доступен без API_TOKEN
+	Public bool
+	// Errors - коды ответов помимо 200 с описаниями
+	Errors map[int]string
+
+	Handle func(ctx context.Context, req *apiRequest) (*APIGatewayResponse, error)
+}
+
+// apiRequest - запрос с уже разобранными параметрами пути
+type apiRequest struct {
+	event  *APIGatewayRequest
+	params map[string]string
+}
+
+func (r *apiRequest) query(name string) string {
+	return r.event.QueryStringParameters[name]
+}
+
+// routeFor выбирает метод по шаблону resource и pathParameters, которые разобрал API Gateway,
+// а без них (локальный запуск, прямой вызов функции) - по пути запроса
+func routeFor(event *APIGatewayRequest) (*route, map[string]string) {
+	method := event.HTTPMethod
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	for i := range routes {
+		if event.Resource != "" && routes[i].Method == method && routes[i].Pattern == event.Resource {
+			return &routes[i], event.PathParameters
+		}
+	}
+
+	return matchRoute(event.HTTPMethod, event.Path)
+}
+
+// matchRoute находит метод по HTTP-методу и пути; параметры {name} шаблона возвращаются раскодированными
+func matchRoute(method, path string) (*route, map[string]string) {
+	if path == "" {
+		path = "/"
+	}
+	if method == http.MethodHead {
+		method = http.MethodGet
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i := range routes {
+		rt := &routes[i]
+		if rt.Method != method {
+			continue
+		}
+
+		if params, ok := matchPattern(rt.Pattern, segments); ok {
+			return rt, params
+		}
+	}
+
+	return nil, nil
+}
+
+func matchPattern(pattern string, segments []string) (map[string]string, bool) {
+	parts := strings.Split(strings.Trim(pattern, "/"), "/")
+	if len(parts) != len(segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			value, err := url.PathUnescape(segments[i])
+			if err != nil || value == "" {
+				return nil, false
+			}
+			params[part[1:len(part)-1]] = value
+
+			continue
+		}
+
+		if part != segments[i] {
+			return nil, false
+		}
+	}
+
+	return params, true
+}
+
+func (rt *route) serve(ctx context.Context, event *APIGatewayRequest, params map[string]string) (*APIGatewayResponse, error) {
+	if !rt.Public && !authorized(event) {
+		if rt.HTML {
+			return redirect(galleryLogin), nil
+		}
+
+		return errorResponse(http.StatusUnauthorized, "missing or invalid API token"), nil
+	}
+
+	return rt.Handle(ctx, &apiRequest{event: event, params: params})
+}
+
+// authorized проверяет заголовок Authorization: Bearer <API_TOKEN> или cookie галереи с тем же токеном.
+// Без API_TOKEN REST API выключен: имена людей не должны быть доступны всем
+func authorized(event *APIGatewayRequest) bool {
+	token := os.Getenv("API_TOKEN")
+	if token == "" {
+		return false
+	}
+
+	got, ok := strings.CutPrefix(header(event, "Authorization"), "Bearer ")
+	if !ok {
+		got = cookie(event, tokenCookie)
+	}
+	if got == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func cookie(event *APIGatewayRequest, name string) string {
+	req := http.Request{Header: http.Header{"Cookie": {header(event, "Cookie")}}}
+
+	c, err := req.Cookie(name)
+	if err != nil {
+		return ""
+	}
+
+	return c.Value
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestMatchRoute(t *testing.T) {
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		wantPattern string
+		wantParams  map[string]string
+	}{
+		{"root", http.MethodGet, "", "/", map[string]string{}},
+		{"list", http.MethodGet, "/faces", "/faces", map[string]string{}},
+		{"trailing slash", http.MethodGet, "/faces/", "/faces", map[string]string{}},
+		{"param", http.MethodGet, "/faces/a.jpg", "/faces/{id}", map[string]string{"id": "a.jpg"}},
+		{"method", http.MethodPatch, "/faces/a.jpg", "/faces/{id}", map[string]string{"id": "a.jpg"}},
+		{"head as get", http.MethodHead, "/openapi.json", "/openapi.json", map[string]string{}},
+		{"escaped param", http.MethodGet, "/people/%D0%90%D0%BD%D0%BD%D0%B0%20%D0%9C/images",
+			"/people/{name}/images", map[string]string{"name": "Анна М"}},
+		{"gallery login", http.MethodPost, "/gallery/login", "/gallery/login", map[string]string{}},
+		{"gallery person", http.MethodGet, "/gallery/people/Ivan", "/gallery/people/{name}", map[string]string{"name": "Ivan"}},
+		{"empty param", http.MethodGet, "/people//images", "", nil},
+		{"bad escape", http.MethodGet, "/faces/%zz", "", nil},
+		{"unknown path", http.MethodGet, "/faces/a.jpg/extra", "", nil},
+		{"unknown method", http.MethodDelete, "/faces/a.jpg", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, params := matchRoute(tt.method, tt.path)
+
+			pattern := ""
+			if rt != nil {
+				pattern = rt.Pattern
+			}
+			if pattern != tt.wantPattern {
+				t.Fatalf("matchRoute(%s %q) pattern = %q, want %q", tt.method, tt.path, pattern, tt.wantPattern)
+			}
+			if !reflect.DeepEqual(params, tt.wantParams) {
+				t.Errorf("matchRoute(%s %q) params = %v, want %v", tt.method, tt.path, params, tt.wantParams)
+			}
+		})
+	}
+}
+
+func TestRouteForResource(t *testing.T) {
+	event := &APIGatewayRequest{
+		HTTPMethod:     http.MethodGet,
+		Resource:       "/images/{id}/faces",
+		Path:           "/images/1.jpg/faces",
+		PathParameters: map[string]string{"id": "1.jpg"},
+	}
+
+	rt, params := routeFor(event)
+	if rt == nil || rt.Pattern != event.Resource {
+		t.Fatalf("routeFor() = %v, want %s", rt, event.Resource)
+	}
+	if params["id"] != "1.jpg" {
+		t.Errorf("routeFor() params = %v", params)
+	}
+}
+
+func TestAuthorized(t *testing.T) {
+	tests := []struct {
+		name    string
+		token   string
+		headers map[string]string
+		want    bool
+	}{
+		{"bearer", "secret", map[string]string{"Authorization": "Bearer secret"}, true},
+		{"header case", "secret", map[string]string{"authorization": "Bearer secret"}, true},
+		{"cookie", "secret", map[string]string{"Cookie": "lang=ru; api_token=secret"}, true},
+		{"wrong bearer", "secret", map[string]string{"Authorization": "Bearer other"}, false},
+		{"wrong scheme", "secret", map[string]string{"Authorization": "Basic secret"}, false},
+		{"wrong cookie", "secret", map[string]string{"Cookie": "api_token=other"}, false},
+		{"no credentials", "secret", nil, false},
+		{"no API_TOKEN", "", map[string]string{"Authorization": "Bearer "}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("API_TOKEN", tt.token)
+
+			if got := authorized(&APIGatewayRequest{Headers: tt.headers}); got != tt.want {
+				t.Errorf("authorized() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestServeRequiresToken(t *testing.T) {
+	t.Setenv("API_TOKEN", "secret")
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+	}{
+		{"rest api", http.MethodGet, "/faces", http.StatusUnauthorized},
+		{"gallery", http.MethodGet, "/gallery", http.StatusSeeOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt, params := matchRoute(tt.method, tt.path)
+			if rt == nil {
+				t.Fatalf("no route for %s %s", tt.method, tt.path)
+			}
+
+			resp, err := rt.serve(context.Background(), &APIGatewayRequest{HTTPMethod: tt.method, Path: tt.path}, params)
+			if err != nil {
+				t.Fatalf("serve() error = %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("serve() status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
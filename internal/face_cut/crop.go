@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+)
+
+// errSkipped - лицо не сохраняется, причина - в тексте обёртки. Задача при этом не повторяется
+var errSkipped = errors.New("face skipped")
+
+// cropOptions - как вырезать лицо; задаются FACE_MARGIN, FACE_SIZE, FACE_MIN_SIZE и FACE_ALIGN
+type cropOptions struct {
+	// Margin - поле вокруг лица с каждой стороны, в долях большей стороны границ детектора
+	Margin float64
+	// Size - сторона итогового квадратного изображения; 0 - без масштабирования
+	Size int
+	// MinSize - лица, у которых меньшая сторона короче, не сохраняются
+	MinSize int
+	// Align поворачивает лицо так, чтобы глаза были на одной высоте
+	Align bool
+}
+
+func cropOptionsFromEnv() cropOptions {
+	// по умолчанию лицо вырезается ровно по границам детектора, как до появления настроек
+	var opts cropOptions
+
+	if v, err := strconv.ParseFloat(os.Getenv("FACE_MARGIN"), 64); err == nil && v >= 0 {
+		opts.Margin = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("FACE_SIZE")); err == nil && v >= 0 {
+		opts.Size = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("FACE_MIN_SIZE")); err == nil && v >= 0 {
+		opts.MinSize = v
+	}
+	opts.Align, _ = strconv.ParseBool(os.Getenv("FACE_ALIGN"))
+
+	return opts
+}
+
+// expand расширяет границы лица до квадрата с полями margin и обрезает его краями фото.
+// Без полей границы детектора остаются как есть
+func expand(face image.Rectangle, margin float64, bounds image.Rectangle) image.Rectangle {
+	if margin == 0 {
+		return face.Intersect(bounds)
+	}
+
+	side := float64(max(face.Dx(), face.Dy())) * (1 + 2*margin)
+	half := int(math.Round(side / 2))
+	center := face.Min.Add(face.Max).Div(2)
+
+	return image.Rect(center.X-half, center.Y-half, center.X+half, center.Y+half).Intersect(bounds)
+}
+
+// cropFace вырезает лицо с полями и приводит его к opts.Size. Если известны глаза, лицо сначала
+// поворачивается вокруг центра так, чтобы они оказались на одной высоте
+func cropFace(img image.Image, face image.Rectangle, eyes []image.Point, opts cropOptions) image.Image {
+	box := expand(face, opts.Margin, img.Bounds())
+
+	var out image.Image
+	if len(eyes) == 2 {
+		angle := math.Atan2(float64(eyes[1].Y-eyes[0].Y), float64(eyes[1].X-eyes[0].X)) * 180 / math.Pi
+		out = rotatedCrop(img, box, angle)
+	} else {
+		out = imaging.Crop(img, box)
+	}
+
+	if opts.Size > 0 {
+		// box обрезан краями фото и может быть не квадратным, Fill берёт середину
+		out = imaging.Fill(out, opts.Size, opts.Size, imaging.Center, imaging.Lanczos)
+	}
+
+	return out
+}
+
+// rotatedCrop поворачивает окрестность box на angle градусов против часовой стрелки вокруг
+// центра box и вырезает из середины область размером box. Всё, что за краями фото, - чёрное
+func rotatedCrop(img image.Image, box image.Rectangle, angle float64) image.Image {
+	// квадрат со стороной в диагональ box покрывает его при любом угле поворота
+	half := int(math.Ceil(math.Hypot(float64(box.Dx()), float64(box.Dy())) / 2))
+	center := box.Min.Add(box.Max).Div(2)
+	area := image.Rect(center.X-half, center.Y-half, center.X+half, center.Y+half)
+
+	src := imaging.New(area.Dx(), area.Dy(), color.Black)
+	if visible := area.Intersect(img.Bounds()); !visible.Empty() {
+		src = imaging.Paste(src, imaging.Crop(img, visible), visible.Min.Sub(area.Min))
+	}
+
+	rotated := imaging.Rotate(src, angle, color.Black)
+
+	return imaging.CropCenter(rotated, box.Dx(), box.Dy())
+}
@@ -0,0 +1,86 @@
+//go:build dlib
+
+package embedding
+
+import (
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+
+	"github.com/Kagami/go-face"
+)
+
+// ModelFiles - модели, без которых go-face не создаёт Recognizer
+var ModelFiles = []string{
+	"dlib_face_recognition_resnet_model_v1.dat",
+	"shape_predictor_5_face_landmarks.dat",
+	"mmod_human_face_detector.dat",
+}
+
+// minOverlap - доля пересечения, при которой лицо dlib считается тем же, что нашёл детектор
+const minOverlap = 0.3
+
+// Dlib считает дескрипторы моделью dlib_face_recognition_resnet_model_v1.dat
+type Dlib struct {
+	rec *face.Recognizer
+
+	// лица последнего изображения: в пакете обычно несколько лиц с одной фотографии
+	lastImage string
+	lastFaces []face.Face
+}
+
+func NewDlib(modelsDir string) (*Dlib, error) {
+	for _, name := range ModelFiles {
+		info, err := os.Stat(filepath.Join(modelsDir, name))
+		if err != nil || info.Size() == 0 {
+			return nil, fmt.Errorf("model %s is missing or empty in %s", name, modelsDir)
+		}
+	}
+
+	rec, err := face.NewRecognizer(modelsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dlib models from %s: %w", modelsDir, err)
+	}
+
+	return &Dlib{rec: rec}, nil
+}
+
+func (e *Dlib) Describe(imageID string, img []byte, bounds image.Rectangle) (Descriptor, bool, error) {
+	if imageID != e.lastImage {
+		faces, err := e.rec.Recognize(img)
+		if err != nil {
+			return Descriptor{}, false, fmt.Errorf("failed to recognize faces: %w", err)
+		}
+
+		e.lastImage = imageID
+		e.lastFaces = faces
+	}
+
+	best := -1
+	bestOverlap := 0.0
+	for i, f := range e.lastFaces {
+		if overlap := iou(f.Rectangle, bounds); overlap > bestOverlap {
+			best = i
+			bestOverlap = overlap
+		}
+	}
+
+	if best < 0 || bestOverlap < minOverlap {
+		return Descriptor{}, false, nil
+	}
+
+	return Descriptor(e.lastFaces[best].Descriptor), true, nil
+}
+
+func iou(a, b image.Rectangle) float64 {
+	inter := a.Intersect(b)
+	if inter.Empty() {
+		return 0
+	}
+
+	interArea := float64(inter.Dx() * inter.Dy())
+	union := float64(a.Dx()*a.Dy()+b.Dx()*b.Dy()) - interArea
+
+	return interArea / union
+}
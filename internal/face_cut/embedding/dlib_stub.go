@@ -0,0 +1,14 @@
+//go:build !dlib
+
+package embedding
+
+import "errors"
+
+type Dlib struct {
+	Encoder
+}
+
+// NewDlib без тега dlib недоступен: go-face требует cgo и установленный dlib
+func NewDlib(_ string) (*Dlib, error) {
+	return nil, errors.New("face embeddings are not available: build with -tags dlib")
+}
@@ -0,0 +1,78 @@
+package embedding
+
+import (
+	"encoding/binary"
+	"fmt"
+	"image"
+	"math"
+)
+
+// DescriptorSize - размер дескриптора dlib_face_recognition_resnet_model_v1
+const DescriptorSize = 128
+
+// DefaultThreshold - порог евклидова расстояния, рекомендованный для модели dlib
+const DefaultThreshold = 0.6
+
+type Descriptor [DescriptorSize]float32
+
+// Encoder считает дескриптор лица, найденного на изображении imageID в границах bounds;
+// img - содержимое изображения
+type Encoder interface {
+	Describe(imageID string, img []byte, bounds image.Rectangle) (Descriptor, bool, error)
+}
+
+// Known - дескриптор лица, которому уже присвоено имя
+type Known struct {
+	FaceID     string
+	FaceName   string
+	Descriptor Descriptor
+}
+
+// Bytes кодирует дескриптор для хранения в колонке String
+func (d Descriptor) Bytes() []byte {
+	buf := make([]byte, 4*DescriptorSize)
+	for i, v := range d {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+
+	return buf
+}
+
+func FromBytes(buf []byte) (Descriptor, error) {
+	d := Descriptor{}
+	if len(buf) != 4*DescriptorSize {
+		return d, fmt.Errorf("invalid descriptor length: %d", len(buf))
+	}
+
+	for i := range d {
+		d[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+
+	return d, nil
+}
+
+func Distance(a, b Descriptor) float64 {
+	sum := 0.0
+	for i := range a {
+		diff := float64(a[i] - b[i])
+		sum += diff * diff
+	}
+
+	return math.Sqrt(sum)
+}
+
+// Nearest ищет ближайшее именованное лицо, расстояние до которого меньше threshold
+func Nearest(d Descriptor, known []Known, threshold float64) (Known, float64, bool) {
+	best := Known{}
+	bestDist := math.Inf(1)
+
+	for _, k := range known {
+		dist := Distance(d, k.Descriptor)
+		if dist < bestDist {
+			best = k
+			bestDist = dist
+		}
+	}
+
+	return best, bestDist, bestDist < threshold
+}
@@ -1,20 +1,26 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"github.com/ydb-platform/ydb-go-sdk/v3/table"
-	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
 	"image"
 	"log"
 	"os"
-	"path"
+	"strconv"
+	"time"
+
+	"2hw/embedding"
+	"2hw/repository"
+	"2hw/shape"
+	"2hw/storage"
+	"2hw/taskqueue"
 
 	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
-	"github.com/ydb-platform/ydb-go-sdk/v3"
-	yc "github.com/ydb-platform/ydb-go-yc-metadata"
+	_ "golang.org/x/image/webp"
 )
 
 type Response struct {
@@ -26,7 +32,8 @@ type Messages struct {
 	Messages []struct {
 		Details struct {
 			Message struct {
-				Body string `json:"body"`
+				MessageID string `json:"message_id"`
+				Body      string `json:"body"`
 			} `json:"message"`
 		} `json:"details"`
 	} `json:"messages"`
@@ -35,6 +42,34 @@ type Messages struct {
 type CutterTask struct {
 	Bounds   FaceBounds `json:"bounds"`
 	ObjectID string     `json:"objectID"`
+	// Total - число лиц, найденных на изображении ObjectID. В задачах старых версий face_detection
+	// поля нет, и бот отвечает на фото после первого же вырезанного лица
+	Total int `json:"total"`
+
+	Provider    string  `json:"provider"`
+	Confidence  float64 `json:"confidence"`
+	ImageWidth  int     `json:"imageWidth"`
+	ImageHeight int     `json:"imageHeight"`
+	// Landmarks - точки лица в пикселях исходного фото
+	Landmarks map[string]FacePoint `json:"landmarks,omitempty"`
+
+	// Failed - face_detection не смог обработать фото. Задачи без границ лица (hasFace)
+	// только отвечают на фото, присланное в бота
+	Failed bool `json:"failed,omitempty"`
+
+	// Attempt - сколько раз задача уже падала и ставилась в очередь заново
+	Attempt int `json:"attempt,omitempty"`
+}
+
+// hasFace отличает задачу с лицом от ответа на фото без лиц. По Total их не различить:
+// задача старой версии face_detection без поля total тоже приходит с Total 0
+func (t *CutterTask) hasFace() bool {
+	return t.Bounds.Width > 0 && t.Bounds.Height > 0
+}
+
+type FacePoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
 }
 
 type FaceBounds struct {
@@ -44,30 +79,120 @@ type FaceBounds struct {
 	Height int `json:"height"`
 }
 
+var (
+	encoder    embedding.Encoder
+	encoderErr error
+)
+
+// getEncoder загружает модели один раз на инстанс функции. Без MODELS_DIR дескрипторы не считаются:
+// лица сохраняются, но имена не подставляются автоматически. Если MODELS_DIR задан, а моделей
+// в нём нет, это ошибка конфигурации - пачка падает, а не режется молча без дескрипторов
+func getEncoder() (embedding.Encoder, error) {
+	if encoder != nil || encoderErr != nil {
+		return encoder, encoderErr
+	}
+
+	modelsDir := os.Getenv("MODELS_DIR")
+	if modelsDir == "" {
+		return nil, nil
+	}
+
+	e, err := embedding.NewDlib(modelsDir)
+	if err != nil {
+		encoderErr = fmt.Errorf("MODELS_DIR is set but face embeddings are unavailable: %w", err)
+		return nil, encoderErr
+	}
+	encoder = e
+
+	return encoder, nil
+}
+
+var (
+	shapes         shape.Predictor
+	shapesDisabled bool
+)
+
+// getShapes загружает 68-точечную модель для выравнивания лиц, если оно включено FACE_ALIGN.
+// Без модели или без сборки с dlib лица вырезаются без выравнивания, причина пишется в лог
+func getShapes(opts cropOptions) shape.Predictor {
+	if shapes != nil || shapesDisabled || !opts.Align {
+		return shapes
+	}
+
+	modelsDir := os.Getenv("MODELS_DIR")
+	if modelsDir == "" {
+		log.Printf("face alignment is disabled: FACE_ALIGN needs MODELS_DIR with %s", shape.ModelFile)
+		shapesDisabled = true
+		return nil
+	}
+
+	p, err := shape.NewDlib(modelsDir)
+	if err != nil {
+		log.Printf("face alignment is disabled: %v", err)
+		shapesDisabled = true
+		return nil
+	}
+	shapes = p
+
+	return shapes
+}
+
+func matchThreshold() float64 {
+	threshold, err := strconv.ParseFloat(os.Getenv("FACE_MATCH_THRESHOLD"), 64)
+	if err != nil || threshold <= 0 {
+		return embedding.DefaultThreshold
+	}
+
+	return threshold
+}
+
+// Статусы сообщения пачки в MessageResult
 const (
-	inputDir  = "/function/storage/images"
-	outputDir = "/function/storage/faces"
+	statusDone    = "done"
+	statusRetry   = "retry"
+	statusDead    = "dead"
+	statusFailed  = "failed"
+	statusSkipped = "skipped"
 )
 
+// MessageResult - итог обработки одной задачи из пачки триггера
+type MessageResult struct {
+	MessageID string `json:"messageID"`
+	ObjectID  string `json:"objectID,omitempty"`
+	FaceID    string `json:"faceID,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// cutter - всё, что нужно для нарезки лиц одной пачки
+type cutter struct {
+	repo      repository.Repository
+	images    storage.BlobStore
+	faces     storage.BlobStore
+	enc       embedding.Encoder
+	known     []embedding.Known
+	threshold float64
+	opts      cropOptions
+	// shapes - nil, если выравнивание выключено
+	shapes shape.Predictor
+}
+
 func Handler(ctx context.Context, request []byte) (*Response, error) {
-	ydbURL := os.Getenv("YDB_URL")
-	db, err := ydb.Open(ctx,
-		ydbURL,
-		yc.WithInternalCA(),
-		yc.WithCredentials(),
-	)
+	repo, closeRepo, err := repository.Open(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to init db connection: %v", err)
+		return nil, err
 	}
-	defer func() {
-		_ = db.Close(ctx)
-	}()
+	defer closeRepo()
 
-	relationsPath := "relations"
-	relationsPath = path.Join(db.Name(), relationsPath)
+	images, err := storage.FromEnv(ctx, storage.Images)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open images storage: %v", err)
+	}
 
-	namesPath := "names"
-	namesPath = path.Join(db.Name(), namesPath)
+	faces, err := storage.FromEnv(ctx, storage.Faces)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open faces storage: %v", err)
+	}
 
 	messages := &Messages{}
 
@@ -77,61 +202,284 @@ func Handler(ctx context.Context, request []byte) (*Response, error) {
 
 	log.Println(messages)
 
-	for _, msg := range messages.Messages {
-		rowTask := msg.Details.Message.Body
+	enc, err := getEncoder()
+	if err != nil {
+		return nil, err
+	}
+
+	var known []embedding.Known
+	if enc != nil {
+		known, err = loadKnown(ctx, repo)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read known descriptors: %v", err)
+		}
+	}
+
+	opts := cropOptionsFromEnv()
+	shapes := getShapes(opts)
 
-		task := &CutterTask{}
+	c := &cutter{
+		repo:      repo,
+		images:    images,
+		faces:     faces,
+		enc:       enc,
+		known:     known,
+		threshold: matchThreshold(),
+		opts:      opts,
+		shapes:    shapes,
+	}
 
-		if err := json.Unmarshal([]byte(rowTask), task); err != nil {
-			return nil, err
+	results := make([]MessageResult, len(messages.Messages))
+	unrouted := 0
+	for i, msg := range messages.Messages {
+		results[i] = c.handleMessage(ctx, msg.Details.Message.MessageID, msg.Details.Message.Body)
+		if results[i].Status == statusFailed {
+			unrouted++
 		}
+	}
 
-		log.Println(task)
+	// ошибка - только если задачу не удалось ни обработать, ни переложить в очередь:
+	// тогда триггер повторит всю пачку
+	if unrouted > 0 {
+		return nil, fmt.Errorf("failed to requeue %d of %d messages", unrouted, len(results))
+	}
 
-		img, err := imaging.Open(path.Join(inputDir, task.ObjectID))
-		if err != nil {
-			return nil, fmt.Errorf("failed to open input img: %s", err)
+	return &Response{
+		StatusCode: 200,
+		Body:       results,
+	}, nil
+}
+
+// handleMessage обрабатывает одну задачу. Упавшая задача ставится в очередь заново, а после
+// maxAttempts попыток или при неисправимой ошибке уходит в очередь недоставленных
+func (c *cutter) handleMessage(ctx context.Context, messageID, body string) MessageResult {
+	res := MessageResult{
+		MessageID: messageID,
+		Status:    statusDone,
+	}
+
+	task := &CutterTask{}
+	err := json.Unmarshal([]byte(body), task)
+	if err != nil {
+		err = taskqueue.Permanent(fmt.Errorf("failed to parse task: %w", err))
+	} else {
+		log.Println(task)
+		res.ObjectID = task.ObjectID
+		if !task.hasFace() {
+			err = notifyUpload(ctx, c.repo, task)
+		} else {
+			res.FaceID, err = c.cut(ctx, task)
 		}
+	}
+	if err == nil {
+		return res
+	}
 
-		bounds := task.Bounds
+	res.Error = err.Error()
+	if errors.Is(err, errSkipped) {
+		log.Printf("message %s: %v", messageID, err)
+		res.Status = statusSkipped
+		return res
+	}
 
-		rectcropimg := imaging.Crop(img, image.Rect(
-			bounds.X, bounds.Y,
-			bounds.X+bounds.Width,
-			bounds.Y+bounds.Height))
+	attempts := task.Attempt + 1
 
-		faceName := uuid.New().String() + ".jpg"
-		if err := imaging.Save(rectcropimg, path.Join(outputDir, faceName)); err != nil {
-			return nil, fmt.Errorf("failed to save img: %v", err)
+	var routeErr error
+	if taskqueue.IsPermanent(err) || attempts >= taskqueue.MaxAttempts() {
+		log.Printf("message %s failed after %d attempts, moving to dead-letter queue: %v", messageID, attempts, err)
+		res.Status = statusDead
+		routeErr = deadLetter(ctx, messageID, body, attempts, err)
+	} else {
+		log.Printf("message %s failed, attempt %d of %d: %v", messageID, attempts, taskqueue.MaxAttempts(), err)
+		res.Status = statusRetry
+		routeErr = retryTask(ctx, task)
+	}
+	if routeErr != nil {
+		log.Printf("failed to route message %s: %v", messageID, routeErr)
+		res.Status = statusFailed
+		res.Error = routeErr.Error()
+	}
+
+	return res
+}
+
+// cut вырезает лицо по задаче, сохраняет его и связь с фото; возвращает имя файла лица
+func (c *cutter) cut(ctx context.Context, task *CutterTask) (string, error) {
+	bounds := task.Bounds
+	rect := image.Rect(
+		bounds.X, bounds.Y,
+		bounds.X+bounds.Width,
+		bounds.Y+bounds.Height)
+	faceName := faceID(task.ObjectID, rect)
+
+	if min(rect.Dx(), rect.Dy()) < c.opts.MinSize {
+		return "", c.skip(ctx, task, faceName, fmt.Sprintf("face is %dx%d, smaller than %dpx", rect.Dx(), rect.Dy(), c.opts.MinSize))
+	}
+
+	imgData, err := c.images.Get(ctx, task.ObjectID)
+	if errors.Is(err, storage.ErrNotFound) {
+		return "", taskqueue.Permanent(fmt.Errorf("input img %s not found", task.ObjectID))
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read input img: %s", err)
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(imgData))
+	if err != nil {
+		return "", taskqueue.Permanent(fmt.Errorf("failed to open input img: %s", err))
+	}
+
+	if rect.Intersect(img.Bounds()).Empty() {
+		return "", taskqueue.Permanent(fmt.Errorf("face bounds %v are outside of the image %v", rect, img.Bounds()))
+	}
+
+	// файл лица с тем же именем - результат прошлой доставки этой же задачи
+	_, err = c.faces.Stat(ctx, faceName)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		buf := &bytes.Buffer{}
+		if err := imaging.Encode(buf, cropFace(img, rect, c.eyes(img, rect), c.opts), imaging.JPEG); err != nil {
+			return "", fmt.Errorf("failed to encode img: %v", err)
 		}
 
-		err = db.Table().BulkUpsert(ctx,
-			namesPath,
-			table.BulkUpsertDataRows(
-				types.ListValue(
-					types.StructValue(
-						types.StructFieldValue("FaceID", types.StringValueFromString(faceName)),
-					))),
-		)
+		if err := c.faces.Put(ctx, faceName, buf.Bytes(), "image/jpeg"); err != nil {
+			return "", fmt.Errorf("failed to save img: %v", err)
+		}
+	case err != nil:
+		return "", fmt.Errorf("failed to check face img: %v", err)
+	default:
+		log.Printf("face %s already saved, task is a redelivery", faceName)
+	}
+
+	face := repository.Face{
+		FaceID:    faceName,
+		Detection: task.detection(img.Bounds()),
+	}
+
+	if c.enc != nil {
+		desc, found, err := c.enc.Describe(task.ObjectID, imgData, rect)
 		if err != nil {
-			return nil, fmt.Errorf("failed to BulkInsert to names: %v", err)
+			return "", fmt.Errorf("failed to compute descriptor: %v", err)
 		}
 
-		err = db.Table().BulkUpsert(ctx,
-			relationsPath,
-			table.BulkUpsertDataRows(
-				types.ListValue(
-					types.StructValue(
-						types.StructFieldValue("ImageID", types.StringValueFromString(task.ObjectID)),
-						types.StructFieldValue("FaceID", types.StringValueFromString(faceName)),
-					))),
-		)
+		if found {
+			face.Descriptor = desc.Bytes()
+
+			if match, dist, ok := embedding.Nearest(desc, c.known, c.threshold); ok {
+				log.Printf("face %s matched %s (%s), distance %.3f", faceName, match.FaceName, match.FaceID, dist)
+				face.FaceName = match.FaceName
+				face.AutoNamed = true
+			}
+		}
+	}
+
+	if err := c.repo.InsertFace(ctx, face, task.ObjectID); err != nil {
+		return "", fmt.Errorf("failed to insert face: %v", err)
+	}
+
+	// ответ в бота не должен приводить к повторной нарезке лиц
+	if err := notifyUpload(ctx, c.repo, task); err != nil {
+		log.Printf("failed to notify upload %s: %v", task.ObjectID, err)
+	}
+
+	return faceName, nil
+}
+
+// skip запоминает пропущенное лицо, чтобы бот не ждал его, и возвращает errSkipped с причиной
+func (c *cutter) skip(ctx context.Context, task *CutterTask, faceName, reason string) error {
+	if err := c.repo.SaveSkippedFace(ctx, faceName, task.ObjectID, reason); err != nil {
+		return fmt.Errorf("failed to save skipped face: %v", err)
+	}
+
+	if err := notifyUpload(ctx, c.repo, task); err != nil {
+		log.Printf("failed to notify upload %s: %v", task.ObjectID, err)
+	}
+
+	return fmt.Errorf("%w: %s", errSkipped, reason)
+}
+
+// eyes находит центры глаз для выравнивания; nil - выравнивание выключено или не удалось
+func (c *cutter) eyes(img image.Image, rect image.Rectangle) []image.Point {
+	if c.shapes == nil {
+		return nil
+	}
+
+	points, err := c.shapes.Predict(img, rect)
+	if err != nil {
+		log.Printf("face is not aligned: %v", err)
+		return nil
+	}
+
+	left, right, ok := shape.Eyes(points)
+	if !ok {
+		log.Printf("face is not aligned: shape predictor returned %d points", len(points))
+		return nil
+	}
+
+	return []image.Point{left, right}
+}
+
+// faceNamespace - пространство имён UUIDv5 для имён файлов лиц
+var faceNamespace = uuid.MustParse("5d1f4c52-8f0e-4a8b-9a53-3c2b7e0f6a11")
+
+// faceID выводит имя файла лица из фото и границ: повторная доставка задачи
+// даёт то же имя, а не новое лицо
+func faceID(objectID string, bounds image.Rectangle) string {
+	key := fmt.Sprintf("%s/%d,%d,%d,%d", objectID, bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y)
+
+	return uuid.NewSHA1(faceNamespace, []byte(key)).String() + ".jpg"
+}
+
+// detection переносит результат детектора из задачи в repository.Detection.
+// В задачах, поставленных до появления этих полей, размеры берутся из самого фото
+func (t *CutterTask) detection(imgBounds image.Rectangle) *repository.Detection {
+	det := &repository.Detection{
+		X:           t.Bounds.X,
+		Y:           t.Bounds.Y,
+		Width:       t.Bounds.Width,
+		Height:      t.Bounds.Height,
+		Provider:    t.Provider,
+		Confidence:  t.Confidence,
+		ImageWidth:  t.ImageWidth,
+		ImageHeight: t.ImageHeight,
+		CreatedAt:   time.Now().UTC(),
+	}
+	if det.ImageWidth == 0 || det.ImageHeight == 0 {
+		det.ImageWidth = imgBounds.Dx()
+		det.ImageHeight = imgBounds.Dy()
+	}
+
+	if len(t.Landmarks) > 0 {
+		det.Landmarks = make(map[string]repository.Point, len(t.Landmarks))
+		for name, p := range t.Landmarks {
+			det.Landmarks[name] = repository.Point{X: p.X, Y: p.Y}
+		}
+	}
+
+	return det
+}
+
+// loadKnown читает дескрипторы лиц, имена которых подтверждены пользователем
+func loadKnown(ctx context.Context, repo repository.Repository) ([]embedding.Known, error) {
+	faces, err := repo.NamedDescriptors(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	known := make([]embedding.Known, 0, len(faces))
+	for _, face := range faces {
+		desc, err := embedding.FromBytes(face.Descriptor)
 		if err != nil {
-			return nil, fmt.Errorf("failed to BulkInsert to relations: %v", err)
+			log.Printf("skip face %s: %v", face.FaceID, err)
+			continue
 		}
+
+		known = append(known, embedding.Known{
+			FaceID:     face.FaceID,
+			FaceName:   face.FaceName,
+			Descriptor: desc,
+		})
 	}
 
-	return &Response{
-		StatusCode: 200,
-	}, nil
+	return known, nil
 }
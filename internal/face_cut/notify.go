@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"2hw/repository"
+	"2hw/telegram"
+)
+
+var bot *telegram.Client
+
+// getBot создаёт клиент Bot API один раз на инстанс функции: повторы, ctx и 429 - как у tg_bot
+func getBot() *telegram.Client {
+	if bot == nil {
+		bot = telegram.New(os.Getenv("TG_API_KEY"), telegram.WithBaseURL(os.Getenv("TG_API_URL")))
+	}
+
+	return bot
+}
+
+// notifyUpload отвечает в чат, если изображение прислали в бота и все его лица уже вырезаны или пропущены.
+// Задача без границ лица приходит от face_detection, когда лиц нет или фото не удалось обработать
+func notifyUpload(ctx context.Context, repo repository.Repository, task *CutterTask) error {
+	upload, err := repo.FindUpload(ctx, task.ObjectID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if upload.Notified {
+		return nil
+	}
+
+	count, err := repo.CountImageFaces(ctx, task.ObjectID)
+	if err != nil {
+		return err
+	}
+
+	skipped, err := repo.CountSkippedFaces(ctx, task.ObjectID)
+	if err != nil {
+		return err
+	}
+
+	if count+skipped < task.Total {
+		return nil
+	}
+
+	text := fmt.Sprintf("На фото найдено лиц: %d. Дайте им имена командой /getface", count)
+	switch {
+	case task.Failed:
+		text = "Не удалось обработать фото, попробуйте прислать его ещё раз"
+	case !task.hasFace():
+		text = "На фото не найдено лиц"
+	case count == 0:
+		text = "Лица на фото слишком маленькие, чтобы их сохранить"
+	}
+	if err := sendReply(ctx, upload.ChatID, text, upload.MessageID); err != nil {
+		return err
+	}
+
+	return repo.MarkUploadNotified(ctx, task.ObjectID)
+}
+
+func sendReply(ctx context.Context, chatID int64, text string, replyToMsgID int64) error {
+	if os.Getenv("TG_API_KEY") == "" {
+		return errors.New("TG_API_KEY is not set")
+	}
+
+	_, err := getBot().SendMessage(ctx, &telegram.SendMessageReq{
+		ChatID:           chatID,
+		Text:             text,
+		ReplyToMessageID: replyToMsgID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send reply tg message: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+
+	"2hw/taskqueue"
+
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+var queueClient *sqs.Client
+
+// getQueue создаёт клиент Message Queue один раз на инстанс функции; нужен только при ошибках
+func getQueue(ctx context.Context) (*sqs.Client, error) {
+	if queueClient != nil {
+		return queueClient, nil
+	}
+
+	client, err := taskqueue.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queueClient = client
+
+	return queueClient, nil
+}
+
+// retryTask ставит задачу в QUEUE_URL заново со следующим номером попытки
+func retryTask(ctx context.Context, task *CutterTask) error {
+	queueURL := os.Getenv("QUEUE_URL")
+	if queueURL == "" {
+		return errors.New("QUEUE_URL is not set")
+	}
+
+	client, err := getQueue(ctx)
+	if err != nil {
+		return err
+	}
+
+	next := *task
+	next.Attempt++
+
+	body, err := json.Marshal(next)
+	if err != nil {
+		return err
+	}
+
+	return taskqueue.Retry(ctx, client, queueURL, body, next.Attempt)
+}
+
+// deadLetter пересылает исходное тело сообщения в очередь недоставленных
+func deadLetter(ctx context.Context, messageID, body string, attempts int, cause error) error {
+	client, err := getQueue(ctx)
+	if err != nil {
+		return err
+	}
+
+	return taskqueue.DeadLetter(ctx, client, messageID, body, attempts, cause)
+}
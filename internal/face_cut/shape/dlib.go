@@ -0,0 +1,85 @@
+//go:build dlib
+
+package shape
+
+// #cgo CXXFLAGS: -std=c++14 -O2
+// #cgo LDFLAGS: -ldlib -lblas -llapack
+// #include <stdlib.h>
+// #include "shape.h"
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"path/filepath"
+	"sync"
+	"unsafe"
+)
+
+// Dlib - Predictor на shape_predictor_68_face_landmarks.dat. go-face грузит только
+// 5-точечную модель, поэтому 68-точечная подключается напрямую
+type Dlib struct {
+	mu sync.Mutex
+	p  *C.shape_predictor_t
+}
+
+func NewDlib(modelsDir string) (*Dlib, error) {
+	path := C.CString(filepath.Join(modelsDir, ModelFile))
+	defer C.free(unsafe.Pointer(path))
+
+	var cerr *C.char
+	p := C.shape_init(path, &cerr)
+	if p == nil {
+		defer C.free(unsafe.Pointer(cerr))
+		return nil, fmt.Errorf("failed to load %s from %s: %s", ModelFile, modelsDir, C.GoString(cerr))
+	}
+
+	return &Dlib{p: p}, nil
+}
+
+func (d *Dlib) Predict(img image.Image, bounds image.Rectangle) ([]image.Point, error) {
+	// модели нужна только окрестность лица, в полутона переводится она, а не всё фото
+	pad := max(bounds.Dx(), bounds.Dy()) / 2
+	area := bounds.Inset(-pad).Intersect(img.Bounds())
+	if area.Empty() {
+		return nil, fmt.Errorf("face bounds %v are outside of the image", bounds)
+	}
+
+	gray := image.NewGray(image.Rect(0, 0, area.Dx(), area.Dy()))
+	draw.Draw(gray, gray.Bounds(), img, area.Min, draw.Src)
+
+	local := bounds.Sub(area.Min)
+	points := make([]C.long, 2*NumPoints)
+
+	var cerr *C.char
+
+	d.mu.Lock()
+	n := C.shape_predict(d.p,
+		(*C.uint8_t)(unsafe.Pointer(&gray.Pix[0])), C.int(area.Dx()), C.int(area.Dy()),
+		// у dlib::rectangle правая и нижняя границы входят в прямоугольник
+		C.long(local.Min.X), C.long(local.Min.Y), C.long(local.Max.X-1), C.long(local.Max.Y-1),
+		&points[0], C.int(NumPoints), &cerr)
+	d.mu.Unlock()
+
+	if n < 0 {
+		defer C.free(unsafe.Pointer(cerr))
+		return nil, fmt.Errorf("failed to predict face shape: %s", C.GoString(cerr))
+	}
+
+	count := min(int(n), NumPoints)
+	shape := make([]image.Point, count)
+	for i := range shape {
+		shape[i] = image.Pt(int(points[2*i]), int(points[2*i+1])).Add(area.Min)
+	}
+
+	return shape, nil
+}
+
+func (d *Dlib) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	C.shape_free(d.p)
+	d.p = nil
+}
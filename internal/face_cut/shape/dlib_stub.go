@@ -0,0 +1,14 @@
+//go:build !dlib
+
+package shape
+
+import "errors"
+
+type Dlib struct {
+	Predictor
+}
+
+// NewDlib без тега dlib недоступен: модели dlib требуют cgo и установленный dlib
+func NewDlib(_ string) (*Dlib, error) {
+	return nil, errors.New("face alignment is not available: build with -tags dlib")
+}
@@ -0,0 +1,52 @@
+//go:build dlib
+
+#include <cstring>
+#include <memory>
+
+#include <dlib/array2d.h>
+#include <dlib/image_processing.h>
+
+#include "shape.h"
+
+struct shape_predictor_t {
+	dlib::shape_predictor sp;
+};
+
+shape_predictor_t* shape_init(const char* path, char** err) {
+	try {
+		std::unique_ptr<shape_predictor_t> p(new shape_predictor_t);
+		dlib::deserialize(path) >> p->sp;
+		return p.release();
+	} catch (const std::exception& e) {
+		*err = strdup(e.what());
+		return nullptr;
+	}
+}
+
+int shape_predict(shape_predictor_t* p, const uint8_t* gray, int width, int height,
+                  long left, long top, long right, long bottom,
+                  long* points, int max_points, char** err) {
+	try {
+		dlib::array2d<unsigned char> img(height, width);
+		for (int y = 0; y < height; y++) {
+			std::memcpy(&img[y][0], gray + (size_t)y * width, width);
+		}
+
+		dlib::full_object_detection shape = p->sp(img, dlib::rectangle(left, top, right, bottom));
+
+		int n = (int)shape.num_parts();
+		for (int i = 0; i < n && i < max_points; i++) {
+			points[2 * i] = shape.part(i).x();
+			points[2 * i + 1] = shape.part(i).y();
+		}
+
+		return n;
+	} catch (const std::exception& e) {
+		*err = strdup(e.what());
+		return -1;
+	}
+}
+
+void shape_free(shape_predictor_t* p) {
+	delete p;
+}
@@ -0,0 +1,37 @@
+package shape
+
+import "image"
+
+// ModelFile - модель dlib с 68 точками лица (разметка iBUG 300-W), лежит в MODELS_DIR
+const ModelFile = "shape_predictor_68_face_landmarks.dat"
+
+const NumPoints = 68
+
+// Predictor находит точки лица, границы которого уже известны
+type Predictor interface {
+	Predict(img image.Image, bounds image.Rectangle) ([]image.Point, error)
+}
+
+// Точки глаз в разметке iBUG: 36-41 - глаз слева на изображении, 42-47 - справа
+var (
+	leftEye  = [2]int{36, 42}
+	rightEye = [2]int{42, 48}
+)
+
+// Eyes возвращает центры глаз: left - глаз, который на изображении левее
+func Eyes(points []image.Point) (left, right image.Point, ok bool) {
+	if len(points) < NumPoints {
+		return image.Point{}, image.Point{}, false
+	}
+
+	return center(points[leftEye[0]:leftEye[1]]), center(points[rightEye[0]:rightEye[1]]), true
+}
+
+func center(points []image.Point) image.Point {
+	sum := image.Point{}
+	for _, p := range points {
+		sum = sum.Add(p)
+	}
+
+	return sum.Div(len(points))
+}
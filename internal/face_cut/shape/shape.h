@@ -0,0 +1,24 @@
+#pragma once
+
+#include <stdint.h>
+
+#ifdef __cplusplus
+extern "C" {
+#endif
+
+typedef struct shape_predictor_t shape_predictor_t;
+
+// shape_init загружает модель; при ошибке возвращает NULL и текст ошибки в err (освобождает вызывающий)
+shape_predictor_t* shape_init(const char* path, char** err);
+
+// shape_predict ищет точки лица в прямоугольнике [left, right] x [top, bottom] полутонового
+// изображения width x height и пишет до max_points пар x, y в points; возвращает число точек или -1
+int shape_predict(shape_predictor_t* p, const uint8_t* gray, int width, int height,
+                  long left, long top, long right, long bottom,
+                  long* points, int max_points, char** err);
+
+void shape_free(shape_predictor_t* p);
+
+#ifdef __cplusplus
+}
+#endif
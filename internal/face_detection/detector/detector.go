@@ -0,0 +1,89 @@
+package detector
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+
+	"2hw/signurl"
+)
+
+const (
+	KindEdenAI = "edenai"
+	KindLocal  = "local"
+	KindFake   = "fake"
+)
+
+// Box - границы лица в долях от размеров изображения (0..1)
+type Box struct {
+	XMin float64 `json:"x_min"`
+	XMax float64 `json:"x_max"`
+	YMin float64 `json:"y_min"`
+	YMax float64 `json:"y_max"`
+}
+
+// Rect переводит нормированные границы в пиксели изображения width x height
+func (b Box) Rect(width, height int) image.Rectangle {
+	w := float64(width)
+	h := float64(height)
+
+	return image.Rect(
+		int(math.Round(w*b.XMin)),
+		int(math.Round(h*b.YMin)),
+		int(math.Round(w*b.XMax)),
+		int(math.Round(h*b.YMax)),
+	).Intersect(image.Rect(0, 0, width, height))
+}
+
+// Point - точка лица в долях от размеров изображения, как и Box
+type Point struct {
+	X float64
+	Y float64
+}
+
+// Pixel переводит точку в пиксели изображения width x height
+func (p Point) Pixel(width, height int) image.Point {
+	return image.Pt(
+		int(math.Round(float64(width)*p.X)),
+		int(math.Round(float64(height)*p.Y)),
+	)
+}
+
+type Face struct {
+	Box Box
+	// Confidence - уверенность детектора 0..1; 0 - детектор её не отдаёт
+	Confidence float64
+	// Landmarks - глаза, нос, рот и т.п., если детектор их отдаёт; ключ - название точки у детектора
+	Landmarks map[string]Point
+}
+
+// FaceDetector ищет лица на изображении из бакета. img - содержимое objectID, которое функция
+// уже прочитала: EdenAI скачивает фото сам по ссылке, локальный детектор работает с img
+type FaceDetector interface {
+	Detect(ctx context.Context, objectID string, img []byte) ([]Face, error)
+	Provider() string
+}
+
+type Config struct {
+	Kind       string
+	Provider   string
+	APIToken   string
+	ModelsDir  string
+	GatewayURL string
+	// Signer подписывает ссылки на фото, которые EdenAI скачивает через API Gateway
+	Signer *signurl.Signer
+}
+
+func New(cfg Config) (FaceDetector, error) {
+	switch cfg.Kind {
+	case "", KindEdenAI:
+		return NewEdenAI(cfg.Provider, cfg.APIToken, cfg.GatewayURL, cfg.Signer)
+	case KindLocal:
+		return NewLocal(cfg.ModelsDir)
+	case KindFake:
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("unknown detector kind: %s", cfg.Kind)
+	}
+}
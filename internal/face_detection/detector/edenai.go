@@ -0,0 +1,165 @@
+package detector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+
+	"2hw/signurl"
+)
+
+type APIRequest struct {
+	Providers string `json:"providers"`
+	FileUrl   string `json:"file_url"`
+}
+
+type ProviderResponse struct {
+	Status string `json:"status"`
+	Error  *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+	Items []struct {
+		Confidence  float64 `json:"confidence"`
+		BoundingBox Box     `json:"bounding_box"`
+		// Landmarks - точки [x, y] в долях изображения; у провайдеров без точек списки пустые
+		Landmarks map[string][]float64 `json:"landmarks"`
+	} `json:"items"`
+}
+
+// APIResponse - ответ EdenAI, ключом является имя провайдера
+type APIResponse map[string]ProviderResponse
+
+const (
+	ProviderAmazon    = "amazon"
+	ProviderGoogle    = "google"
+	ProviderMicrosoft = "microsoft"
+	ProviderClarifai  = "clarifai"
+)
+
+const apiURL = "https://api.edenai.run/v2/image/face_detection"
+
+var providers = map[string]bool{
+	ProviderAmazon:    true,
+	ProviderGoogle:    true,
+	ProviderMicrosoft: true,
+	ProviderClarifai:  true,
+}
+
+// EdenAI отправляет ссылку на изображение через API Gateway в EdenAI
+type EdenAI struct {
+	provider   string
+	token      string
+	gatewayURL string
+	signer     *signurl.Signer
+	client     *http.Client
+}
+
+// NewEdenAI требует signer: API Gateway отдаёт фото только по подписанной ссылке
+func NewEdenAI(provider, token, gatewayURL string, signer *signurl.Signer) (*EdenAI, error) {
+	if provider == "" {
+		provider = ProviderAmazon
+	}
+	if !providers[provider] {
+		return nil, fmt.Errorf("unsupported edenai provider: %s", provider)
+	}
+	if token == "" {
+		return nil, fmt.Errorf("edenai detector: EDENAI_API_TOKEN is not set")
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("edenai detector: %w", signurl.ErrNoKey)
+	}
+
+	return &EdenAI{
+		provider:   provider,
+		token:      token,
+		gatewayURL: gatewayURL,
+		signer:     signer,
+		client:     &http.Client{},
+	}, nil
+}
+
+func (d *EdenAI) Provider() string {
+	return d.provider
+}
+
+func (d *EdenAI) Detect(ctx context.Context, objectID string, _ []byte) ([]Face, error) {
+	apiReq := &APIRequest{
+		Providers: d.provider,
+		FileUrl:   d.signer.URL(d.gatewayURL, url.Values{"image": {objectID}}),
+	}
+	jsonStr, err := json.Marshal(apiReq)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonStr))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("authorization", "Bearer "+d.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	log.Println("edenai response status:", resp.Status)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("edenai request failed: %s %s", resp.Status, string(body))
+	}
+
+	apiResp := APIResponse{}
+
+	if err := json.Unmarshal(body, &apiResp); err != nil {
+		return nil, err
+	}
+
+	result, ok := apiResp[d.provider]
+	if !ok {
+		return nil, fmt.Errorf("edenai response has no %s result", d.provider)
+	}
+	if result.Status != "" && result.Status != "success" {
+		msg := result.Status
+		if result.Error != nil {
+			msg = result.Error.Message
+		}
+		return nil, fmt.Errorf("edenai %s failed: %s", d.provider, msg)
+	}
+
+	faces := make([]Face, 0, len(result.Items))
+	for _, item := range result.Items {
+		faces = append(faces, Face{
+			Box:        item.BoundingBox,
+			Confidence: item.Confidence,
+			Landmarks:  edenAILandmarks(item.Landmarks),
+		})
+	}
+
+	return faces, nil
+}
+
+func edenAILandmarks(raw map[string][]float64) map[string]Point {
+	landmarks := map[string]Point{}
+	for name, xy := range raw {
+		if len(xy) >= 2 {
+			landmarks[name] = Point{X: xy[0], Y: xy[1]}
+		}
+	}
+
+	if len(landmarks) == 0 {
+		return nil
+	}
+
+	return landmarks
+}
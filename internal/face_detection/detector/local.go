@@ -0,0 +1,102 @@
+//go:build dlib
+
+package detector
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/Kagami/go-face"
+)
+
+// modelFiles - модели, без которых go-face не создаёт Recognizer
+var modelFiles = []string{
+	"mmod_human_face_detector.dat",
+	"shape_predictor_5_face_landmarks.dat",
+	"dlib_face_recognition_resnet_model_v1.dat",
+}
+
+// Local ищет лица прямо в функции через go-face: лица находит mmod_human_face_detector.dat,
+// но Recognizer загружает и остальные модели go-face, см. README
+type Local struct {
+	mu  sync.Mutex
+	rec *face.Recognizer
+}
+
+func NewLocal(modelsDir string) (FaceDetector, error) {
+	// в models/ лежат пустые заглушки, dlib на них падает с невнятной ошибкой
+	for _, name := range modelFiles {
+		info, err := os.Stat(filepath.Join(modelsDir, name))
+		if err != nil || info.Size() == 0 {
+			return nil, fmt.Errorf("model %s is missing or empty in %s", name, modelsDir)
+		}
+	}
+
+	rec, err := face.NewRecognizer(modelsDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dlib models from %s: %w", modelsDir, err)
+	}
+
+	return &Local{rec: rec}, nil
+}
+
+func (d *Local) Provider() string {
+	return KindLocal
+}
+
+func (d *Local) Detect(_ context.Context, _ string, img []byte) ([]Face, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image config: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	found, err := d.rec.RecognizeCNN(img)
+	if err != nil {
+		return nil, fmt.Errorf("failed to detect faces: %w", err)
+	}
+
+	w := float64(cfg.Width)
+	h := float64(cfg.Height)
+	faces := make([]Face, 0, len(found))
+	for _, f := range found {
+		faces = append(faces, Face{
+			Box: Box{
+				XMin: float64(f.Rectangle.Min.X) / w,
+				XMax: float64(f.Rectangle.Max.X) / w,
+				YMin: float64(f.Rectangle.Min.Y) / h,
+				YMax: float64(f.Rectangle.Max.Y) / h,
+			},
+			// go-face не отдаёт оценку mmod детектора, Confidence 0 - оценки нет
+			Landmarks: shapeLandmarks(f.Shapes, w, h),
+		})
+	}
+
+	return faces, nil
+}
+
+// shapeLandmarks - точки shape predictor по порядку: shape_0, shape_1, ...
+func shapeLandmarks(shapes []image.Point, w, h float64) map[string]Point {
+	if len(shapes) == 0 {
+		return nil
+	}
+
+	landmarks := make(map[string]Point, len(shapes))
+	for i, p := range shapes {
+		landmarks["shape_"+strconv.Itoa(i)] = Point{
+			X: float64(p.X) / w,
+			Y: float64(p.Y) / h,
+		}
+	}
+
+	return landmarks
+}
@@ -0,0 +1,10 @@
+//go:build !dlib
+
+package detector
+
+import "errors"
+
+// NewLocal без тега dlib недоступен: go-face требует cgo и установленный dlib
+func NewLocal(_ string) (FaceDetector, error) {
+	return nil, errors.New("local detector is not available: build with -tags dlib")
+}
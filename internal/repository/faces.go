@@ -0,0 +1,573 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+const (
+	// insertName, insertRelation и insertDetection пишут строку, только если её ещё нет:
+	// повторная доставка задачи face_cut не затирает имя, которое успел дать пользователь,
+	// и время обнаружения лица
+	insertName = `
+		DECLARE $faceID AS String;
+		DECLARE $name AS String?;
+		DECLARE $autoNamed AS Bool;
+		DECLARE $descriptor AS String?;
+
+		UPSERT INTO names (FaceID, FaceName, AutoNamed, Descriptor)
+		SELECT t.FaceID AS FaceID, t.FaceName AS FaceName, t.AutoNamed AS AutoNamed, t.Descriptor AS Descriptor
+		FROM AS_TABLE(AsList(AsStruct(
+			$faceID AS FaceID, $name AS FaceName, $autoNamed AS AutoNamed, $descriptor AS Descriptor
+		))) AS t
+		LEFT ONLY JOIN names AS n ON t.FaceID = n.FaceID;`
+
+	insertRelation = `
+		DECLARE $faceID AS String;
+		DECLARE $imageID AS String;
+
+		UPSERT INTO relations (FaceID, ImageID)
+		SELECT t.FaceID AS FaceID, t.ImageID AS ImageID
+		FROM AS_TABLE(AsList(AsStruct($faceID AS FaceID, $imageID AS ImageID))) AS t
+		LEFT ONLY JOIN relations AS r ON t.FaceID = r.FaceID;`
+
+	insertDetection = `
+		DECLARE $faceID AS String;
+		DECLARE $imageID AS String;
+		DECLARE $x AS Int32;
+		DECLARE $y AS Int32;
+		DECLARE $width AS Int32;
+		DECLARE $height AS Int32;
+		DECLARE $provider AS String;
+		DECLARE $confidence AS Double;
+		DECLARE $imageWidth AS Int32;
+		DECLARE $imageHeight AS Int32;
+		DECLARE $landmarks AS String?;
+		DECLARE $createdAt AS Timestamp;
+
+		UPSERT INTO relations (FaceID, ImageID, X, Y, Width, Height, Provider, Confidence,
+			ImageWidth, ImageHeight, Landmarks, CreatedAt)
+		SELECT t.FaceID AS FaceID, t.ImageID AS ImageID, t.X AS X, t.Y AS Y,
+			t.Width AS Width, t.Height AS Height, t.Provider AS Provider, t.Confidence AS Confidence,
+			t.ImageWidth AS ImageWidth, t.ImageHeight AS ImageHeight,
+			t.Landmarks AS Landmarks, t.CreatedAt AS CreatedAt
+		FROM AS_TABLE(AsList(AsStruct(
+			$faceID AS FaceID, $imageID AS ImageID, $x AS X, $y AS Y, $width AS Width, $height AS Height,
+			$provider AS Provider, $confidence AS Confidence, $imageWidth AS ImageWidth,
+			$imageHeight AS ImageHeight, $landmarks AS Landmarks, $createdAt AS CreatedAt
+		))) AS t
+		LEFT ONLY JOIN relations AS r ON t.FaceID = r.FaceID;`
+
+	setName = `
+		DECLARE $faceID AS String;
+		DECLARE $name AS String;
+
+		UPSERT INTO names (FaceID, FaceName, AutoNamed)
+		VALUES ($faceID, $name, false);`
+
+	selectImagesByName = `
+		DECLARE $name AS String;
+
+		SELECT    r.ImageID AS image
+		FROM      (SELECT FaceID FROM names WHERE FaceName = $name) AS n
+		INNER  JOIN relations AS r ON n.FaceID = r.FaceID;`
+
+	selectNames = `
+		SELECT DISTINCT FaceName
+		FROM names
+		WHERE FaceName IS NOT NULL;`
+
+	selectUnnamed = `
+		SELECT FaceID, FaceName, SkippedAt
+		FROM names
+		WHERE (FaceName IS NULL OR AutoNamed) AND NOT COALESCE(NotFace, false)
+		ORDER BY SkippedAt
+		LIMIT 1;`
+
+	selectTopNames = `
+		DECLARE $limit AS Uint64;
+
+		SELECT FaceName, COUNT(*) AS cnt
+		FROM names
+		WHERE FaceName IS NOT NULL AND NOT COALESCE(AutoNamed, false)
+		GROUP BY FaceName
+		ORDER BY cnt DESC
+		LIMIT $limit;`
+
+	setSkipped = `
+		DECLARE $faceID AS String;
+
+		UPDATE names SET SkippedAt = CurrentUtcTimestamp()
+		WHERE FaceID = $faceID;`
+
+	setNotFace = `
+		DECLARE $faceID AS String;
+
+		UPDATE names SET NotFace = true
+		WHERE FaceID = $faceID;`
+
+	deleteFace = `
+		DECLARE $faceID AS String;
+
+		DELETE FROM names WHERE FaceID = $faceID;
+		DELETE FROM relations WHERE FaceID = $faceID;`
+
+	selectFaces = `
+		DECLARE $named AS Bool?;
+		DECLARE $after AS String;
+		DECLARE $limit AS Uint64;
+
+		SELECT n.FaceID AS FaceID, n.FaceName AS FaceName, n.AutoNamed AS AutoNamed, r.ImageID AS ImageID,
+			r.X AS X, r.Y AS Y, r.Width AS Width, r.Height AS Height, r.Provider AS Provider,
+			r.Confidence AS Confidence, r.ImageWidth AS ImageWidth, r.ImageHeight AS ImageHeight,
+			r.Landmarks AS Landmarks, r.CreatedAt AS CreatedAt
+		FROM names AS n
+		LEFT JOIN relations AS r ON n.FaceID = r.FaceID
+		WHERE n.FaceID > $after AND NOT COALESCE(n.NotFace, false)
+			AND ($named IS NULL OR $named = (n.FaceName IS NOT NULL AND NOT COALESCE(n.AutoNamed, false)))
+		ORDER BY FaceID
+		LIMIT $limit;`
+
+	selectFace = `
+		DECLARE $faceID AS String;
+
+		SELECT n.FaceID AS FaceID, n.FaceName AS FaceName, n.AutoNamed AS AutoNamed, r.ImageID AS ImageID,
+			r.X AS X, r.Y AS Y, r.Width AS Width, r.Height AS Height, r.Provider AS Provider,
+			r.Confidence AS Confidence, r.ImageWidth AS ImageWidth, r.ImageHeight AS ImageHeight,
+			r.Landmarks AS Landmarks, r.CreatedAt AS CreatedAt
+		FROM names AS n
+		LEFT JOIN relations AS r ON n.FaceID = r.FaceID
+		WHERE n.FaceID = $faceID;`
+
+	selectImageFaces = `
+		DECLARE $imageID AS String;
+
+		SELECT n.FaceID AS FaceID, n.FaceName AS FaceName, n.AutoNamed AS AutoNamed, r.ImageID AS ImageID,
+			r.X AS X, r.Y AS Y, r.Width AS Width, r.Height AS Height, r.Provider AS Provider,
+			r.Confidence AS Confidence, r.ImageWidth AS ImageWidth, r.ImageHeight AS ImageHeight,
+			r.Landmarks AS Landmarks, r.CreatedAt AS CreatedAt
+		FROM relations AS r
+		INNER JOIN names AS n ON n.FaceID = r.FaceID
+		WHERE r.ImageID = $imageID AND NOT COALESCE(n.NotFace, false)
+		ORDER BY FaceID;`
+
+	selectNamedDescriptors = `
+		SELECT FaceID, FaceName, Descriptor
+		FROM names
+		WHERE FaceName IS NOT NULL AND Descriptor IS NOT NULL AND NOT COALESCE(AutoNamed, false);`
+)
+
+// InsertFace сохраняет лицо и его связь с фото одной транзакцией: лица в names без строки
+// в relations /getface бы показывал, а /find не находил. Повторный вызов с тем же FaceID
+// ничего не меняет, поэтому задачу face_cut можно доставить ещё раз
+func (r *YDB) InsertFace(ctx context.Context, face Face, imageID string) error {
+	var (
+		name       *[]byte
+		descriptor *[]byte
+	)
+	if face.FaceName != "" {
+		b := []byte(face.FaceName)
+		name = &b
+	}
+	if len(face.Descriptor) > 0 {
+		descriptor = &face.Descriptor
+	}
+
+	nameParams := query.WithParameters(ydb.ParamsBuilder().
+		Param("$faceID").Bytes([]byte(face.FaceID)).
+		Param("$name").BeginOptional().Bytes(name).EndOptional().
+		Param("$autoNamed").Bool(face.AutoNamed).
+		Param("$descriptor").BeginOptional().Bytes(descriptor).EndOptional().
+		Build())
+
+	relationQuery := insertRelation
+	relationParams := query.WithParameters(ydb.ParamsBuilder().
+		Param("$faceID").Bytes([]byte(face.FaceID)).
+		Param("$imageID").Bytes([]byte(imageID)).
+		Build())
+	if face.Detection != nil {
+		relationQuery = insertDetection
+
+		var err error
+		relationParams, err = detectionParams(face.FaceID, imageID, face.Detection)
+		if err != nil {
+			return err
+		}
+	}
+
+	// обе вставки идемпотентны, поэтому DoTx может повторять транзакцию и после
+	// ошибок, при которых неизвестно, была ли она закоммичена
+	err := r.c.DoTx(ctx,
+		func(ctx context.Context, tx query.TxActor) error {
+			if err := tx.Exec(ctx, insertName, nameParams); err != nil {
+				return fmt.Errorf("failed to insert into names: %w", err)
+			}
+
+			if err := tx.Exec(ctx, relationQuery, relationParams); err != nil {
+				return fmt.Errorf("failed to insert into relations: %w", err)
+			}
+
+			return nil
+		},
+		query.WithIdempotent(),
+		query.WithTxSettings(query.TxSettings(query.WithSerializableReadWrite())),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert face %s: %w", face.FaceID, err)
+	}
+
+	return nil
+}
+
+func detectionParams(faceID, imageID string, det *Detection) (query.ExecuteOption, error) {
+	var landmarks *[]byte
+	if len(det.Landmarks) > 0 {
+		b, err := json.Marshal(det.Landmarks)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode landmarks: %w", err)
+		}
+		landmarks = &b
+	}
+
+	createdAt := det.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = time.Now().UTC()
+	}
+
+	return query.WithParameters(ydb.ParamsBuilder().
+		Param("$faceID").Bytes([]byte(faceID)).
+		Param("$imageID").Bytes([]byte(imageID)).
+		Param("$x").Int32(int32(det.X)).
+		Param("$y").Int32(int32(det.Y)).
+		Param("$width").Int32(int32(det.Width)).
+		Param("$height").Int32(int32(det.Height)).
+		Param("$provider").Bytes([]byte(det.Provider)).
+		Param("$confidence").Double(det.Confidence).
+		Param("$imageWidth").Int32(int32(det.ImageWidth)).
+		Param("$imageHeight").Int32(int32(det.ImageHeight)).
+		Param("$landmarks").BeginOptional().Bytes(landmarks).EndOptional().
+		Param("$createdAt").Timestamp(createdAt).
+		Build()), nil
+}
+
+func (r *YDB) NameFace(ctx context.Context, faceID, name string) error {
+	return r.exec(ctx, setName,
+		query.WithParameters(ydb.ParamsBuilder().
+			Param("$faceID").Bytes([]byte(faceID)).
+			Param("$name").Bytes([]byte(name)).
+			Build()),
+	)
+}
+
+func (r *YDB) FindImagesByName(ctx context.Context, name string) ([]string, error) {
+	var images []string
+
+	err := r.selectRows(ctx, selectImagesByName,
+		query.WithParameters(ydb.ParamsBuilder().Param("$name").Bytes([]byte(name)).Build()),
+		func() { images = nil },
+		func(row query.Row) error {
+			img := ""
+			if err := row.Scan(&img); err != nil {
+				return err
+			}
+
+			images = append(images, img)
+
+			return nil
+		},
+	)
+
+	return images, err
+}
+
+// ListNames возвращает все различные присвоенные имена
+func (r *YDB) ListNames(ctx context.Context) ([]string, error) {
+	var names []string
+
+	err := r.selectRows(ctx, selectNames,
+		query.WithParameters(ydb.ParamsBuilder().Build()),
+		func() { names = nil },
+		func(row query.Row) error {
+			var name *string
+			if err := row.Scan(&name); err != nil {
+				return err
+			}
+
+			if name != nil {
+				names = append(names, *name)
+			}
+
+			return nil
+		},
+	)
+
+	return names, err
+}
+
+func (r *YDB) NextUnnamedFace(ctx context.Context) (Face, error) {
+	var face *Face
+
+	err := r.selectRows(ctx, selectUnnamed,
+		query.WithParameters(ydb.ParamsBuilder().Build()),
+		func() { face = nil },
+		func(row query.Row) error {
+			var (
+				faceID    string
+				name      *string
+				skippedAt *time.Time
+			)
+			if err := row.Scan(&faceID, &name, &skippedAt); err != nil {
+				return err
+			}
+
+			face = &Face{FaceID: faceID}
+			if name != nil {
+				face.FaceName = *name
+				face.AutoNamed = true
+			}
+
+			return nil
+		},
+	)
+	if err != nil {
+		return Face{}, err
+	}
+
+	if face == nil {
+		return Face{}, ErrNotFound
+	}
+
+	return *face, nil
+}
+
+func (r *YDB) NamedDescriptors(ctx context.Context) ([]Face, error) {
+	var faces []Face
+
+	err := r.selectRows(ctx, selectNamedDescriptors,
+		query.WithParameters(ydb.ParamsBuilder().Build()),
+		func() { faces = nil },
+		func(row query.Row) error {
+			var (
+				faceID     string
+				name       *string
+				descriptor *[]byte
+			)
+			if err := row.Scan(&faceID, &name, &descriptor); err != nil {
+				return err
+			}
+
+			faces = append(faces, Face{
+				FaceID:     faceID,
+				FaceName:   *name,
+				Descriptor: *descriptor,
+			})
+
+			return nil
+		},
+	)
+
+	return faces, err
+}
+
+func (r *YDB) TopNames(ctx context.Context, limit int) ([]string, error) {
+	var names []string
+
+	err := r.selectRows(ctx, selectTopNames,
+		query.WithParameters(ydb.ParamsBuilder().Param("$limit").Uint64(uint64(limit)).Build()),
+		func() { names = nil },
+		func(row query.Row) error {
+			var (
+				name *string
+				cnt  uint64
+			)
+			if err := row.Scan(&name, &cnt); err != nil {
+				return err
+			}
+
+			if name != nil {
+				names = append(names, *name)
+			}
+
+			return nil
+		},
+	)
+
+	return names, err
+}
+
+func (r *YDB) SkipFace(ctx context.Context, faceID string) error {
+	return r.exec(ctx, setSkipped,
+		query.WithParameters(ydb.ParamsBuilder().Param("$faceID").Bytes([]byte(faceID)).Build()),
+	)
+}
+
+func (r *YDB) MarkNotFace(ctx context.Context, faceID string) error {
+	return r.exec(ctx, setNotFace,
+		query.WithParameters(ydb.ParamsBuilder().Param("$faceID").Bytes([]byte(faceID)).Build()),
+	)
+}
+
+func (r *YDB) DeleteFace(ctx context.Context, faceID string) error {
+	return r.exec(ctx, deleteFace,
+		query.WithParameters(ydb.ParamsBuilder().Param("$faceID").Bytes([]byte(faceID)).Build()),
+	)
+}
+
+// defaultFacesLimit ограничивает ListFaces без явного Limit
+const defaultFacesLimit = 100
+
+func (r *YDB) ListFaces(ctx context.Context, filter FaceFilter) ([]Face, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultFacesLimit
+	}
+
+	var faces []Face
+
+	err := r.selectRows(ctx, selectFaces,
+		query.WithParameters(ydb.ParamsBuilder().
+			Param("$named").BeginOptional().Bool(filter.Named).EndOptional().
+			Param("$after").Bytes([]byte(filter.After)).
+			Param("$limit").Uint64(uint64(limit)).
+			Build()),
+		func() { faces = nil },
+		func(row query.Row) error {
+			face, err := scanFace(row)
+			if err != nil {
+				return err
+			}
+
+			faces = append(faces, face)
+
+			return nil
+		},
+	)
+
+	return faces, err
+}
+
+func (r *YDB) GetFace(ctx context.Context, faceID string) (Face, error) {
+	var face *Face
+
+	err := r.selectRows(ctx, selectFace,
+		query.WithParameters(ydb.ParamsBuilder().Param("$faceID").Bytes([]byte(faceID)).Build()),
+		func() { face = nil },
+		func(row query.Row) error {
+			f, err := scanFace(row)
+			if err != nil {
+				return err
+			}
+			face = &f
+
+			return nil
+		},
+	)
+	if err != nil {
+		return Face{}, err
+	}
+
+	if face == nil {
+		return Face{}, ErrNotFound
+	}
+
+	return *face, nil
+}
+
+func (r *YDB) ImageFaces(ctx context.Context, imageID string) ([]Face, error) {
+	var faces []Face
+
+	err := r.selectRows(ctx, selectImageFaces,
+		query.WithParameters(ydb.ParamsBuilder().Param("$imageID").Bytes([]byte(imageID)).Build()),
+		func() { faces = nil },
+		func(row query.Row) error {
+			face, err := scanFace(row)
+			if err != nil {
+				return err
+			}
+
+			faces = append(faces, face)
+
+			return nil
+		},
+	)
+
+	return faces, err
+}
+
+// scanFace читает строку FaceID, FaceName, AutoNamed, ImageID
+func scanFace(row query.Row) (Face, error) {
+	var (
+		faceID      string
+		name        *string
+		autoNamed   *bool
+		imageID     *string
+		x           *int32
+		y           *int32
+		width       *int32
+		height      *int32
+		provider    *string
+		confidence  *float64
+		imageWidth  *int32
+		imageHeight *int32
+		landmarks   *[]byte
+		createdAt   *time.Time
+	)
+	err := row.Scan(&faceID, &name, &autoNamed, &imageID,
+		&x, &y, &width, &height, &provider,
+		&confidence, &imageWidth, &imageHeight,
+		&landmarks, &createdAt)
+	if err != nil {
+		return Face{}, err
+	}
+
+	face := Face{FaceID: faceID}
+	if name != nil {
+		face.FaceName = *name
+	}
+	if autoNamed != nil {
+		face.AutoNamed = *autoNamed
+	}
+	if imageID != nil {
+		face.ImageID = *imageID
+	}
+
+	// строки relations без колонок детектора записаны до их появления
+	if x == nil || y == nil || width == nil || height == nil {
+		return face, nil
+	}
+
+	det := &Detection{
+		X:      int(*x),
+		Y:      int(*y),
+		Width:  int(*width),
+		Height: int(*height),
+	}
+	if provider != nil {
+		det.Provider = *provider
+	}
+	if confidence != nil {
+		det.Confidence = *confidence
+	}
+	if imageWidth != nil {
+		det.ImageWidth = int(*imageWidth)
+	}
+	if imageHeight != nil {
+		det.ImageHeight = int(*imageHeight)
+	}
+	if landmarks != nil {
+		if err := json.Unmarshal(*landmarks, &det.Landmarks); err != nil {
+			return Face{}, fmt.Errorf("bad landmarks of %s: %w", faceID, err)
+		}
+	}
+	if createdAt != nil {
+		det.CreatedAt = *createdAt
+	}
+	face.Detection = det
+
+	return face, nil
+}
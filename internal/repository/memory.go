@@ -0,0 +1,424 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// KindMemory - значение REPOSITORY, при котором функции работают с Shared вместо YDB
+const KindMemory = "memory"
+
+type memoryFace struct {
+	Face
+	NotFace   bool
+	SkippedAt time.Time
+}
+
+type sentFaceKey struct {
+	chatID    int64
+	messageID int64
+}
+
+// Memory - реализация Repository в памяти процесса для локального запуска без YDB
+type Memory struct {
+	mu sync.Mutex
+
+	// faces хранится в порядке добавления, как строки таблицы names
+	faces     []*memoryFace
+	relations map[string][]string
+	sentFaces map[sentFaceKey]string
+	uploads   map[string]Upload
+	// skipped - ImageID пропущенных лиц по FaceID
+	skipped map[string]string
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		relations: map[string][]string{},
+		sentFaces: map[sentFaceKey]string{},
+		uploads:   map[string]Upload{},
+		skipped:   map[string]string{},
+	}
+}
+
+var (
+	shared     *Memory
+	sharedOnce sync.Once
+)
+
+// Shared возвращает общий для всех функций процесса репозиторий в памяти
+func Shared() *Memory {
+	sharedOnce.Do(func() {
+		shared = NewMemory()
+	})
+
+	return shared
+}
+
+// CheckSchema у репозитория в памяти всегда проходит: схемы у него нет
+func (m *Memory) CheckSchema(_ context.Context) error {
+	return nil
+}
+
+func (m *Memory) find(faceID string) *memoryFace {
+	for _, f := range m.faces {
+		if f.FaceID == faceID {
+			return f
+		}
+	}
+
+	return nil
+}
+
+func (m *Memory) InsertFace(_ context.Context, face Face, imageID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if face.Detection != nil && face.Detection.CreatedAt.IsZero() {
+		det := *face.Detection
+		det.CreatedAt = time.Now().UTC()
+		face.Detection = &det
+	}
+
+	// как и в YDB, существующие строки names и relations не меняются
+	if m.find(face.FaceID) == nil {
+		m.faces = append(m.faces, &memoryFace{Face: face})
+	}
+	if len(m.relations[face.FaceID]) == 0 {
+		m.relations[face.FaceID] = []string{imageID}
+	}
+
+	return nil
+}
+
+func (m *Memory) NameFace(_ context.Context, faceID, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f := m.find(faceID)
+	if f == nil {
+		f = &memoryFace{Face: Face{FaceID: faceID}}
+		m.faces = append(m.faces, f)
+	}
+	f.FaceName = name
+	f.AutoNamed = false
+
+	return nil
+}
+
+func (m *Memory) FindImagesByName(_ context.Context, name string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var images []string
+	for _, f := range m.faces {
+		if f.FaceName == name {
+			images = append(images, m.relations[f.FaceID]...)
+		}
+	}
+
+	return images, nil
+}
+
+func (m *Memory) ListNames(_ context.Context) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := map[string]bool{}
+	var names []string
+	for _, f := range m.faces {
+		if f.FaceName != "" && !seen[f.FaceName] {
+			seen[f.FaceName] = true
+			names = append(names, f.FaceName)
+		}
+	}
+
+	return names, nil
+}
+
+func (m *Memory) NextUnnamedFace(_ context.Context) (Face, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var next *memoryFace
+	for _, f := range m.faces {
+		if (f.FaceName != "" && !f.AutoNamed) || f.NotFace {
+			continue
+		}
+
+		// как и ORDER BY SkippedAt в YDB: ни разу не пропущенные лица идут первыми
+		if next == nil || f.SkippedAt.Before(next.SkippedAt) {
+			next = f
+		}
+	}
+
+	if next == nil {
+		return Face{}, ErrNotFound
+	}
+
+	return Face{
+		FaceID:    next.FaceID,
+		FaceName:  next.FaceName,
+		AutoNamed: next.FaceName != "",
+	}, nil
+}
+
+func (m *Memory) NamedDescriptors(_ context.Context) ([]Face, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var faces []Face
+	for _, f := range m.faces {
+		if f.FaceName != "" && len(f.Descriptor) > 0 && !f.AutoNamed {
+			faces = append(faces, f.Face)
+		}
+	}
+
+	return faces, nil
+}
+
+func (m *Memory) TopNames(_ context.Context, limit int) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	counts := map[string]int{}
+	var names []string
+	for _, f := range m.faces {
+		if f.FaceName == "" || f.AutoNamed {
+			continue
+		}
+		if counts[f.FaceName] == 0 {
+			names = append(names, f.FaceName)
+		}
+		counts[f.FaceName]++
+	}
+
+	sort.SliceStable(names, func(i, j int) bool {
+		return counts[names[i]] > counts[names[j]]
+	})
+
+	if len(names) > limit {
+		names = names[:limit]
+	}
+
+	return names, nil
+}
+
+func (m *Memory) SkipFace(_ context.Context, faceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f := m.find(faceID); f != nil {
+		f.SkippedAt = time.Now().UTC()
+	}
+
+	return nil
+}
+
+func (m *Memory) MarkNotFace(_ context.Context, faceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if f := m.find(faceID); f != nil {
+		f.NotFace = true
+	}
+
+	return nil
+}
+
+func (m *Memory) DeleteFace(_ context.Context, faceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, f := range m.faces {
+		if f.FaceID == faceID {
+			m.faces = append(m.faces[:i], m.faces[i+1:]...)
+			break
+		}
+	}
+	delete(m.relations, faceID)
+
+	return nil
+}
+
+// confirmed - имя присвоено пользователем, а не найдено по дескриптору
+func (f *memoryFace) confirmed() bool {
+	return f.FaceName != "" && !f.AutoNamed
+}
+
+// withImage копирует лицо и добавляет к нему изображение из relations
+func (m *Memory) withImage(f *memoryFace) Face {
+	face := f.Face
+	if images := m.relations[f.FaceID]; len(images) > 0 {
+		face.ImageID = images[0]
+	}
+
+	return face
+}
+
+func (m *Memory) ListFaces(_ context.Context, filter FaceFilter) ([]Face, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultFacesLimit
+	}
+
+	var faces []Face
+	for _, f := range m.faces {
+		if f.NotFace || f.FaceID <= filter.After {
+			continue
+		}
+		if filter.Named != nil && *filter.Named != f.confirmed() {
+			continue
+		}
+
+		faces = append(faces, m.withImage(f))
+	}
+
+	sort.Slice(faces, func(i, j int) bool {
+		return faces[i].FaceID < faces[j].FaceID
+	})
+
+	if len(faces) > limit {
+		faces = faces[:limit]
+	}
+
+	return faces, nil
+}
+
+func (m *Memory) GetFace(_ context.Context, faceID string) (Face, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f := m.find(faceID)
+	if f == nil {
+		return Face{}, ErrNotFound
+	}
+
+	return m.withImage(f), nil
+}
+
+func (m *Memory) ImageFaces(_ context.Context, imageID string) ([]Face, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var faces []Face
+	for _, f := range m.faces {
+		if f.NotFace {
+			continue
+		}
+
+		for _, img := range m.relations[f.FaceID] {
+			if img == imageID {
+				face := f.Face
+				face.ImageID = imageID
+				faces = append(faces, face)
+				break
+			}
+		}
+	}
+
+	sort.Slice(faces, func(i, j int) bool {
+		return faces[i].FaceID < faces[j].FaceID
+	})
+
+	return faces, nil
+}
+
+func (m *Memory) SaveSentFace(_ context.Context, chatID, messageID int64, faceID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.sentFaces[sentFaceKey{chatID, messageID}] = faceID
+
+	return nil
+}
+
+func (m *Memory) FindSentFace(_ context.Context, chatID, messageID int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	faceID, ok := m.sentFaces[sentFaceKey{chatID, messageID}]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return faceID, nil
+}
+
+func (m *Memory) SaveUpload(_ context.Context, upload Upload) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload.Notified = false
+	m.uploads[upload.ImageID] = upload
+
+	return nil
+}
+
+func (m *Memory) FindUpload(_ context.Context, imageID string) (Upload, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, ok := m.uploads[imageID]
+	if !ok {
+		return Upload{}, ErrNotFound
+	}
+
+	return upload, nil
+}
+
+func (m *Memory) MarkUploadNotified(_ context.Context, imageID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if upload, ok := m.uploads[imageID]; ok {
+		upload.Notified = true
+		m.uploads[imageID] = upload
+	}
+
+	return nil
+}
+
+func (m *Memory) CountImageFaces(_ context.Context, imageID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, images := range m.relations {
+		for _, img := range images {
+			if img == imageID {
+				count++
+			}
+		}
+	}
+
+	return count, nil
+}
+
+func (m *Memory) SaveSkippedFace(_ context.Context, faceID, imageID, _ string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.skipped[faceID] = imageID
+
+	return nil
+}
+
+func (m *Memory) CountSkippedFaces(_ context.Context, imageID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := 0
+	for _, img := range m.skipped {
+		if img == imageID {
+			count++
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,263 @@
+package repository
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// Миграции лежат в migrations/NNNN_name.up.yql и NNNN_name.down.yql. Версия схемы -
+// наибольшая Version в таблице schema_version; DDL в YDB не транзакционный, поэтому
+// версия записывается после того, как миграция целиком применилась
+//
+//go:embed migrations/*.yql
+var migrationsFS embed.FS
+
+var ErrSchemaBehind = errors.New("database schema is behind")
+
+const (
+	createSchemaVersion = `
+		CREATE TABLE IF NOT EXISTS schema_version (
+			Version Int32 NOT NULL,
+			Name String,
+			AppliedAt Timestamp,
+			PRIMARY KEY (Version)
+		);`
+
+	selectSchemaVersion = `
+		SELECT MAX(Version) AS Version FROM schema_version;`
+
+	upsertSchemaVersion = `
+		DECLARE $version AS Int32;
+		DECLARE $name AS String;
+
+		UPSERT INTO schema_version (Version, Name, AppliedAt)
+		VALUES ($version, $name, CurrentUtcTimestamp());`
+
+	deleteSchemaVersion = `
+		DECLARE $version AS Int32;
+
+		DELETE FROM schema_version WHERE Version = $version;`
+)
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations возвращает встроенные миграции по возрастанию версии
+func Migrations() ([]Migration, error) {
+	files, err := migrationsFS.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, f := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(f.Name(), ".yql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("bad migration file name %s", f.Name())
+		}
+
+		num, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(num)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("bad migration version in %s", f.Name())
+		}
+
+		body, err := migrationsFS.ReadFile(path.Join("migrations", f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, name)
+		}
+
+		if direction == "up" {
+			m.Up = string(body)
+		} else {
+			m.Down = string(body)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// LatestVersion - версия схемы, с которой работает этот код
+func LatestVersion() int {
+	migrations, err := Migrations()
+	if err != nil || len(migrations) == 0 {
+		return 0
+	}
+
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion возвращает текущую версию схемы базы. Таблицы schema_version нет, пока
+// cmd/migrate ни разу не запускался: такая база - версии 0
+func (r *YDB) SchemaVersion(ctx context.Context) (int, error) {
+	version := 0
+
+	err := r.selectRows(ctx, selectSchemaVersion,
+		query.WithParameters(ydb.ParamsBuilder().Build()),
+		func() { version = 0 },
+		func(row query.Row) error {
+			var v *int32
+			if err := row.Scan(&v); err != nil {
+				return err
+			}
+
+			if v != nil {
+				version = int(*v)
+			}
+
+			return nil
+		},
+	)
+	if ydb.IsOperationErrorSchemeError(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema_version: %w", err)
+	}
+
+	return version, nil
+}
+
+// CheckSchema не даёт функциям работать со старой схемой: запросы к отсутствующим
+// колонкам падали бы уже посреди обработки
+func (r *YDB) CheckSchema(ctx context.Context) error {
+	version, err := r.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	if latest := LatestVersion(); version < latest {
+		return fmt.Errorf("%w: database is at version %d, code needs %d; run cmd/migrate up", ErrSchemaBehind, version, latest)
+	}
+
+	return nil
+}
+
+// MigrateUp применяет миграции до target включительно; target <= 0 - все
+func (r *YDB) MigrateUp(ctx context.Context, target int) ([]Migration, error) {
+	if err := r.ddl(ctx, createSchemaVersion); err != nil {
+		return nil, fmt.Errorf("failed to create schema_version: %w", err)
+	}
+
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := r.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if target > 0 && m.Version > target {
+			break
+		}
+
+		if err := r.ddl(ctx, m.Up); err != nil {
+			return applied, fmt.Errorf("migration %d_%s failed: %w", m.Version, m.Name, err)
+		}
+
+		err := r.exec(ctx, upsertSchemaVersion,
+			query.WithParameters(ydb.ParamsBuilder().
+				Param("$version").Int32(int32(m.Version)).
+				Param("$name").Bytes([]byte(m.Name)).
+				Build()),
+		)
+		if err != nil {
+			return applied, fmt.Errorf("failed to record migration %d: %w", m.Version, err)
+		}
+
+		applied = append(applied, m)
+	}
+
+	return applied, nil
+}
+
+// MigrateDown откатывает миграции новее target; target = 0 откатывает все
+func (r *YDB) MigrateDown(ctx context.Context, target int) ([]Migration, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := r.SchemaVersion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version > current {
+			continue
+		}
+		if m.Version <= target {
+			break
+		}
+		if m.Down == "" {
+			return reverted, fmt.Errorf("migration %d_%s can not be reverted: no down script", m.Version, m.Name)
+		}
+
+		if err := r.ddl(ctx, m.Down); err != nil {
+			return reverted, fmt.Errorf("revert of %d_%s failed: %w", m.Version, m.Name, err)
+		}
+
+		err := r.exec(ctx, deleteSchemaVersion,
+			query.WithParameters(ydb.ParamsBuilder().Param("$version").Int32(int32(m.Version)).Build()),
+		)
+		if err != nil {
+			return reverted, fmt.Errorf("failed to record revert of %d: %w", m.Version, err)
+		}
+
+		reverted = append(reverted, m)
+	}
+
+	return reverted, nil
+}
+
+// ddl выполняет изменения схемы: YDB не допускает их внутри транзакции
+func (r *YDB) ddl(ctx context.Context, q string) error {
+	return r.c.Do(ctx,
+		func(ctx context.Context, s query.Session) error {
+			return s.Exec(ctx, q, query.WithTxControl(query.NoTx()))
+		},
+	)
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	yc "github.com/ydb-platform/ydb-go-yc-metadata"
+)
+
+// Open подключается к YDB_URL и сверяет версию схемы. Для локального запуска REPOSITORY=memory
+// подменяет YDB общим репозиторием в памяти, а grpc:// адрес - YDB без TLS и авторизации
+func Open(ctx context.Context) (Repository, func(), error) {
+	if os.Getenv("REPOSITORY") == KindMemory {
+		return Shared(), func() {}, nil
+	}
+
+	ydbURL := os.Getenv("YDB_URL")
+	opts := []ydb.Option{
+		yc.WithInternalCA(),
+		yc.WithCredentials(),
+	}
+	if strings.HasPrefix(ydbURL, "grpc://") {
+		opts = nil
+	}
+
+	db, err := ydb.Open(ctx, ydbURL, opts...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to init db connection: %v", err)
+	}
+
+	repo := New(db.Query())
+	closeDB := func() {
+		_ = db.Close(ctx)
+	}
+
+	if err := checkSchemaOnce(ctx, repo); err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+
+	return repo, closeDB, nil
+}
+
+var (
+	schemaMu      sync.Mutex
+	schemaChecked bool
+)
+
+// checkSchemaOnce сверяет версию схемы один раз на инстанс функции: со старой схемой
+// запросы падали бы посреди обработки, а не при старте
+func checkSchemaOnce(ctx context.Context, repo Repository) error {
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+
+	if schemaChecked {
+		return nil
+	}
+
+	if err := repo.CheckSchema(ctx); err != nil {
+		return fmt.Errorf("schema check failed: %w", err)
+	}
+	schemaChecked = true
+
+	return nil
+}
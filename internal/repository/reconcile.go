@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+const selectFaceRefs = `
+	SELECT n.FaceID AS NameFaceID, r.FaceID AS RelationFaceID, r.ImageID AS ImageID
+	FROM names AS n
+	FULL JOIN relations AS r ON n.FaceID = r.FaceID;`
+
+// FaceRef - лицо с отметкой, в каких таблицах оно есть; по нему сверка находит строки без пары
+type FaceRef struct {
+	FaceID      string
+	InNames     bool
+	InRelations bool
+	// ImageID - из relations, пусто без строки в relations
+	ImageID string
+}
+
+// FaceRefs возвращает все лица из names и relations, в том числе те, что есть только в одной из них
+func (r *YDB) FaceRefs(ctx context.Context) ([]FaceRef, error) {
+	var refs []FaceRef
+
+	err := r.selectRows(ctx, selectFaceRefs,
+		query.WithParameters(ydb.ParamsBuilder().Build()),
+		func() { refs = nil },
+		func(row query.Row) error {
+			var nameFaceID, relationFaceID, imageID *string
+			if err := row.Scan(&nameFaceID, &relationFaceID, &imageID); err != nil {
+				return err
+			}
+
+			ref := FaceRef{
+				InNames:     nameFaceID != nil,
+				InRelations: relationFaceID != nil,
+			}
+			if nameFaceID != nil {
+				ref.FaceID = *nameFaceID
+			} else if relationFaceID != nil {
+				ref.FaceID = *relationFaceID
+			}
+			if imageID != nil {
+				ref.ImageID = *imageID
+			}
+			refs = append(refs, ref)
+
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read names and relations: %w", err)
+	}
+
+	return refs, nil
+}
@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+var ErrNotFound = errors.New("not found")
+
+// Face - строка таблицы names
+type Face struct {
+	FaceID     string
+	FaceName   string
+	AutoNamed  bool
+	Descriptor []byte
+	// ImageID берётся из relations и заполняется только ListFaces, GetFace и ImageFaces
+	ImageID string
+	// Detection записывается InsertFace в relations и читается вместе с ImageID.
+	// nil у лиц, сохранённых до появления этих колонок
+	Detection *Detection
+}
+
+// Detection - что детектор сообщил о лице на исходном фото
+type Detection struct {
+	// X, Y, Width, Height - границы лица в пикселях исходного фото
+	X      int
+	Y      int
+	Width  int
+	Height int
+
+	Provider   string
+	Confidence float64
+
+	ImageWidth  int
+	ImageHeight int
+	// Landmarks - точки лица в пикселях исходного фото, если детектор их отдаёт
+	Landmarks map[string]Point
+	CreatedAt time.Time
+}
+
+type Point struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+// FaceFilter - условия выборки ListFaces
+type FaceFilter struct {
+	// Named: nil - все лица, true - с подтверждённым именем, false - без имени или с автоматическим
+	Named *bool
+	// After - FaceID, после которого начинается страница
+	After string
+	Limit int
+}
+
+// Repository - доступ к таблицам names, relations, sent_faces, uploads и skipped_faces
+type Repository interface {
+	InsertFace(ctx context.Context, face Face, imageID string) error
+	NameFace(ctx context.Context, faceID, name string) error
+	FindImagesByName(ctx context.Context, name string) ([]string, error)
+	ListNames(ctx context.Context) ([]string, error)
+	// NextUnnamedFace возвращает лицо без имени или с автоматически присвоенным именем
+	NextUnnamedFace(ctx context.Context) (Face, error)
+	NamedDescriptors(ctx context.Context) ([]Face, error)
+	// TopNames возвращает самые часто присваиваемые имена
+	TopNames(ctx context.Context, limit int) ([]string, error)
+	// SkipFace откладывает лицо в конец очереди NextUnnamedFace
+	SkipFace(ctx context.Context, faceID string) error
+	MarkNotFace(ctx context.Context, faceID string) error
+	DeleteFace(ctx context.Context, faceID string) error
+	// ListFaces возвращает лица, кроме отмеченных "не лицо", по возрастанию FaceID
+	ListFaces(ctx context.Context, filter FaceFilter) ([]Face, error)
+	// GetFace возвращает лицо или ErrNotFound
+	GetFace(ctx context.Context, faceID string) (Face, error)
+	ImageFaces(ctx context.Context, imageID string) ([]Face, error)
+
+	SaveSentFace(ctx context.Context, chatID, messageID int64, faceID string) error
+	FindSentFace(ctx context.Context, chatID, messageID int64) (string, error)
+
+	SaveUpload(ctx context.Context, upload Upload) error
+	FindUpload(ctx context.Context, imageID string) (Upload, error)
+	MarkUploadNotified(ctx context.Context, imageID string) error
+	CountImageFaces(ctx context.Context, imageID string) (int, error)
+
+	// SaveSkippedFace запоминает лицо, которое face_cut не стал сохранять, и причину
+	SaveSkippedFace(ctx context.Context, faceID, imageID, reason string) error
+	CountSkippedFaces(ctx context.Context, imageID string) (int, error)
+
+	// CheckSchema возвращает ErrSchemaBehind, если база не обновлена до LatestVersion
+	CheckSchema(ctx context.Context) error
+}
+
+type YDB struct {
+	c query.Client
+}
+
+func New(c query.Client) *YDB {
+	return &YDB{c: c}
+}
+
+func readTx() query.ExecuteOption {
+	return query.WithTxControl(query.TxControl(query.BeginTx(query.WithSnapshotReadOnly())))
+}
+
+func writeTx() query.ExecuteOption {
+	return query.WithTxControl(query.TxControl(query.BeginTx(query.WithSerializableReadWrite()), query.CommitTx()))
+}
+
+func (r *YDB) exec(ctx context.Context, q string, params query.ExecuteOption) error {
+	return r.c.Do(ctx,
+		func(ctx context.Context, s query.Session) error {
+			return s.Exec(ctx, q, params, writeTx())
+		},
+	)
+}
+
+// selectRows вызывает scan для каждой строки всех result set'ов запроса
+func (r *YDB) selectRows(ctx context.Context, q string, params query.ExecuteOption, reset func(), scan func(row query.Row) error) error {
+	return r.c.Do(ctx,
+		func(ctx context.Context, s query.Session) (err error) {
+			// Do может повторить операцию, поэтому результат предыдущей попытки сбрасывается
+			reset()
+
+			result, err := s.Query(ctx, q, params, readTx())
+			if err != nil {
+				return err
+			}
+
+			defer func() {
+				_ = result.Close(ctx)
+			}()
+
+			for {
+				set, err := result.NextResultSet(ctx)
+				if err != nil {
+					if errors.Is(err, io.EOF) {
+						break
+					}
+
+					return err
+				}
+
+				for {
+					row, err := set.NextRow(ctx)
+					if err != nil {
+						if errors.Is(err, io.EOF) {
+							break
+						}
+
+						return err
+					}
+
+					if err := scan(row); err != nil {
+						return err
+					}
+				}
+			}
+
+			return nil
+		},
+	)
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+const (
+	upsertSentFace = `
+		DECLARE $chatID AS Int64;
+		DECLARE $messageID AS Int64;
+		DECLARE $faceID AS String;
+
+		UPSERT INTO sent_faces (ChatID, MessageID, FaceID)
+		VALUES ($chatID, $messageID, $faceID);`
+
+	selectSentFace = `
+		DECLARE $chatID AS Int64;
+		DECLARE $messageID AS Int64;
+
+		SELECT FaceID
+		FROM sent_faces
+		WHERE ChatID = $chatID AND MessageID = $messageID;`
+)
+
+func (r *YDB) SaveSentFace(ctx context.Context, chatID, messageID int64, faceID string) error {
+	return r.exec(ctx, upsertSentFace,
+		query.WithParameters(ydb.ParamsBuilder().
+			Param("$chatID").Int64(chatID).
+			Param("$messageID").Int64(messageID).
+			Param("$faceID").Bytes([]byte(faceID)).
+			Build()),
+	)
+}
+
+// FindSentFace возвращает лицо, отправленное ботом в сообщении messageID, или ErrNotFound
+func (r *YDB) FindSentFace(ctx context.Context, chatID, messageID int64) (string, error) {
+	faceID := ""
+
+	err := r.selectRows(ctx, selectSentFace,
+		query.WithParameters(ydb.ParamsBuilder().
+			Param("$chatID").Int64(chatID).
+			Param("$messageID").Int64(messageID).
+			Build()),
+		func() { faceID = "" },
+		func(row query.Row) error {
+			return row.Scan(&faceID)
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if faceID == "" {
+		return "", ErrNotFound
+	}
+
+	return faceID, nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+const (
+	upsertSkippedFace = `
+		DECLARE $faceID AS String;
+		DECLARE $imageID AS String;
+		DECLARE $reason AS String;
+
+		UPSERT INTO skipped_faces (FaceID, ImageID, Reason, SkippedAt)
+		VALUES ($faceID, $imageID, $reason, CurrentUtcTimestamp());`
+
+	countSkippedFaces = `
+		DECLARE $imageID AS String;
+
+		SELECT COUNT(*)
+		FROM skipped_faces
+		WHERE ImageID = $imageID;`
+)
+
+func (r *YDB) SaveSkippedFace(ctx context.Context, faceID, imageID, reason string) error {
+	return r.exec(ctx, upsertSkippedFace,
+		query.WithParameters(ydb.ParamsBuilder().
+			Param("$faceID").Bytes([]byte(faceID)).
+			Param("$imageID").Bytes([]byte(imageID)).
+			Param("$reason").Bytes([]byte(reason)).
+			Build()),
+	)
+}
+
+func (r *YDB) CountSkippedFaces(ctx context.Context, imageID string) (int, error) {
+	var count uint64
+
+	err := r.selectRows(ctx, countSkippedFaces,
+		query.WithParameters(ydb.ParamsBuilder().Param("$imageID").Bytes([]byte(imageID)).Build()),
+		func() { count = 0 },
+		func(row query.Row) error {
+			return row.Scan(&count)
+		},
+	)
+
+	return int(count), err
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ydb-platform/ydb-go-sdk/v3"
+	"github.com/ydb-platform/ydb-go-sdk/v3/query"
+)
+
+// Upload - фото, присланное в бота; по нему бот отвечает, когда лица вырезаны
+type Upload struct {
+	ImageID   string
+	ChatID    int64
+	MessageID int64
+	Notified  bool
+}
+
+const (
+	upsertUpload = `
+		DECLARE $imageID AS String;
+		DECLARE $chatID AS Int64;
+		DECLARE $messageID AS Int64;
+
+		UPSERT INTO uploads (ImageID, ChatID, MessageID, Notified)
+		VALUES ($imageID, $chatID, $messageID, false);`
+
+	selectUpload = `
+		DECLARE $imageID AS String;
+
+		SELECT ImageID, ChatID, MessageID, Notified
+		FROM uploads
+		WHERE ImageID = $imageID;`
+
+	setUploadNotified = `
+		DECLARE $imageID AS String;
+
+		UPDATE uploads SET Notified = true
+		WHERE ImageID = $imageID;`
+
+	countImageFaces = `
+		DECLARE $imageID AS String;
+
+		SELECT COUNT(*)
+		FROM relations
+		WHERE ImageID = $imageID;`
+)
+
+func (r *YDB) SaveUpload(ctx context.Context, upload Upload) error {
+	return r.exec(ctx, upsertUpload,
+		query.WithParameters(ydb.ParamsBuilder().
+			Param("$imageID").Bytes([]byte(upload.ImageID)).
+			Param("$chatID").Int64(upload.ChatID).
+			Param("$messageID").Int64(upload.MessageID).
+			Build()),
+	)
+}
+
+func (r *YDB) FindUpload(ctx context.Context, imageID string) (Upload, error) {
+	var upload *Upload
+
+	err := r.selectRows(ctx, selectUpload,
+		query.WithParameters(ydb.ParamsBuilder().Param("$imageID").Bytes([]byte(imageID)).Build()),
+		func() { upload = nil },
+		func(row query.Row) error {
+			u := Upload{}
+			if err := row.Scan(&u.ImageID, &u.ChatID, &u.MessageID, &u.Notified); err != nil {
+				return err
+			}
+			upload = &u
+
+			return nil
+		},
+	)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	if upload == nil {
+		return Upload{}, ErrNotFound
+	}
+
+	return *upload, nil
+}
+
+func (r *YDB) MarkUploadNotified(ctx context.Context, imageID string) error {
+	return r.exec(ctx, setUploadNotified,
+		query.WithParameters(ydb.ParamsBuilder().Param("$imageID").Bytes([]byte(imageID)).Build()),
+	)
+}
+
+func (r *YDB) CountImageFaces(ctx context.Context, imageID string) (int, error) {
+	var count uint64
+
+	err := r.selectRows(ctx, countImageFaces,
+		query.WithParameters(ydb.ParamsBuilder().Param("$imageID").Bytes([]byte(imageID)).Build()),
+		func() { count = 0 },
+		func(row query.Row) error {
+			return row.Scan(&count)
+		},
+	)
+
+	return int(count), err
+}
@@ -0,0 +1,3 @@
+module 2hw/signurl
+
+go 1.21.0
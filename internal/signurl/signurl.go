@@ -0,0 +1,140 @@
+// Package signurl подписывает ссылки на API Gateway HMAC-SHA256 со сроком действия,
+// чтобы файлы из бакетов отдавались только по ссылкам, выданным ботом
+package signurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ParamExpires   = "exp"
+	ParamSignature = "sig"
+
+	DefaultTTL = 24 * time.Hour
+)
+
+var (
+	ErrNoKey     = errors.New("URL_SIGNING_KEY is not set")
+	ErrUnsigned  = errors.New("url is not signed")
+	ErrExpired   = errors.New("url has expired")
+	ErrSignature = errors.New("url signature mismatch")
+)
+
+type Signer struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+func New(key []byte, ttl time.Duration) *Signer {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	return &Signer{
+		key: key,
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// FromEnv читает ключ из URL_SIGNING_KEY и срок действия ссылок из URL_TTL (например, 1h)
+func FromEnv() (*Signer, error) {
+	key := os.Getenv("URL_SIGNING_KEY")
+	if key == "" {
+		return nil, ErrNoKey
+	}
+
+	ttl, err := time.ParseDuration(os.Getenv("URL_TTL"))
+	if err != nil {
+		ttl = DefaultTTL
+	}
+
+	return New([]byte(key), ttl), nil
+}
+
+// Sign добавляет к параметрам exp и sig. Срок округляется до конца следующего окна ttl:
+// в пределах окна ссылка на один и тот же файл не меняется и кэшируется клиентами
+func (s *Signer) Sign(params url.Values) url.Values {
+	signed := url.Values{}
+	for k, v := range params {
+		if k != ParamExpires && k != ParamSignature {
+			signed[k] = v
+		}
+	}
+
+	window := int64(s.ttl / time.Second)
+	if window < 1 {
+		window = 1
+	}
+	exp := (s.now().Unix()/window + 2) * window
+
+	signed.Set(ParamExpires, strconv.FormatInt(exp, 10))
+	signed.Set(ParamSignature, hex.EncodeToString(s.signature(signed)))
+
+	return signed
+}
+
+// URL возвращает подписанную ссылку https://domain/?params
+func (s *Signer) URL(domain string, params url.Values) string {
+	return "https://" + domain + "/?" + s.Sign(params).Encode()
+}
+
+// Verify проверяет подпись и срок действия параметров запроса
+func (s *Signer) Verify(params map[string]string) error {
+	sig := params[ParamSignature]
+	expStr := params[ParamExpires]
+	if sig == "" || expStr == "" {
+		return ErrUnsigned
+	}
+
+	values := url.Values{}
+	for k, v := range params {
+		values.Set(k, v)
+	}
+
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.signature(values)) {
+		return ErrSignature
+	}
+
+	exp, err := strconv.ParseInt(expStr, 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+	if s.now().Unix() > exp {
+		return ErrExpired
+	}
+
+	return nil
+}
+
+// signature считает HMAC от отсортированных параметров, кроме самой подписи
+func (s *Signer) signature(params url.Values) []byte {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != ParamSignature {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(params.Get(k)))
+	}
+
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(strings.Join(parts, "&")))
+
+	return mac.Sum(nil)
+}
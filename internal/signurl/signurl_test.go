@@ -0,0 +1,122 @@
+package signurl
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+func newTestSigner(key string, now time.Time) *Signer {
+	s := New([]byte(key), time.Hour)
+	s.now = func() time.Time { return now }
+
+	return s
+}
+
+func flat(values url.Values) map[string]string {
+	params := map[string]string{}
+	for k := range values {
+		params[k] = values.Get(k)
+	}
+
+	return params
+}
+
+func TestVerify(t *testing.T) {
+	signed := flat(newTestSigner("key", start).Sign(url.Values{"face": {"a.jpg"}}))
+
+	with := func(k, v string) map[string]string {
+		params := map[string]string{}
+		for pk, pv := range signed {
+			params[pk] = pv
+		}
+		params[k] = v
+
+		return params
+	}
+	without := func(k string) map[string]string {
+		params := with(k, "")
+		delete(params, k)
+
+		return params
+	}
+
+	tests := []struct {
+		name    string
+		signer  *Signer
+		params  map[string]string
+		wantErr error
+	}{
+		{"valid", newTestSigner("key", start), signed, nil},
+		{"valid until the end of the window", newTestSigner("key", start.Add(time.Hour+29*time.Minute)), signed, nil},
+		{"expired", newTestSigner("key", start.Add(2*time.Hour)), signed, ErrExpired},
+		{"other key", newTestSigner("other", start), signed, ErrSignature},
+		{"changed param", newTestSigner("key", start), with("face", "b.jpg"), ErrSignature},
+		{"added param", newTestSigner("key", start), with("image", "1.jpg"), ErrSignature},
+		{"extended expiry", newTestSigner("key", start), with(ParamExpires, "9999999999"), ErrSignature},
+		{"signature is not hex", newTestSigner("key", start), with(ParamSignature, "zz"), ErrSignature},
+		{"no signature", newTestSigner("key", start), without(ParamSignature), ErrUnsigned},
+		{"no expiry", newTestSigner("key", start), without(ParamExpires), ErrUnsigned},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.signer.Verify(tt.params); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignExpiry(t *testing.T) {
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"middle of the window", start, time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)},
+		{"start of the window", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)},
+		{"end of the window", time.Date(2024, 5, 1, 12, 59, 59, 0, time.UTC), time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			signed := newTestSigner("key", tt.now).Sign(url.Values{"face": {"a.jpg"}})
+
+			exp, err := strconv.ParseInt(signed.Get(ParamExpires), 10, 64)
+			if err != nil {
+				t.Fatalf("bad %s: %v", ParamExpires, err)
+			}
+			if got := time.Unix(exp, 0).UTC(); !got.Equal(tt.want) {
+				t.Errorf("expires at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignIsStableWithinWindow(t *testing.T) {
+	params := url.Values{"image": {"1.jpg"}, "size": {"320"}}
+
+	a := newTestSigner("key", start).URL("gw.example.com", params)
+	b := newTestSigner("key", start.Add(20*time.Minute)).URL("gw.example.com", params)
+	if a != b {
+		t.Errorf("URL changed within the window: %q != %q", a, b)
+	}
+	if !strings.HasPrefix(a, "https://gw.example.com/?") {
+		t.Errorf("URL() = %q", a)
+	}
+}
+
+func TestSignReplacesOldSignature(t *testing.T) {
+	s := newTestSigner("key", start)
+	signed := s.Sign(url.Values{"face": {"a.jpg"}, ParamSignature: {"old"}, ParamExpires: {"1"}})
+
+	if err := s.Verify(flat(signed)); err != nil {
+		t.Errorf("Verify() error = %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FS хранит объекты файлами в каталоге, например в смонтированном бакете
+type FS struct {
+	dir string
+}
+
+func NewFS(dir string) *FS {
+	return &FS{dir: dir}
+}
+
+func (s *FS) Get(_ context.Context, key string) ([]byte, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return data, err
+}
+
+func (s *FS) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	fi, err := os.Stat(path.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return ObjectInfo{}, ErrNotFound
+	}
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+
+	return ObjectInfo{
+		Size:    fi.Size(),
+		ModTime: fi.ModTime(),
+	}, nil
+}
+
+func (s *FS) List(_ context.Context) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	err := filepath.WalkDir(s.dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) && file == s.dir {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		fi, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		key, err := filepath.Rel(s.dir, file)
+		if err != nil {
+			return err
+		}
+
+		objects = append(objects, ObjectInfo{
+			Key:     filepath.ToSlash(key),
+			Size:    fi.Size(),
+			ModTime: fi.ModTime(),
+		})
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+func (s *FS) Put(_ context.Context, key string, data []byte, _ string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	file := path.Join(s.dir, key)
+	// ключ может содержать префикс, как в бакете
+	if err := os.MkdirAll(path.Dir(file), 0o755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, data, 0o644)
+}
+
+func (s *FS) Delete(_ context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	if err := os.Remove(path.Join(s.dir, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory хранит объекты в памяти процесса: для тестов и локального запуска
+type Memory struct {
+	mu      sync.RWMutex
+	objects map[string]memoryObject
+}
+
+type memoryObject struct {
+	data    []byte
+	modTime time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{objects: map[string]memoryObject{}}
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   = map[string]*Memory{}
+)
+
+// Shared возвращает общее для всех функций процесса хранилище name
+func Shared(name string) *Memory {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+
+	m, ok := shared[name]
+	if !ok {
+		m = NewMemory()
+		shared[name] = m
+	}
+
+	return m
+}
+
+func (s *Memory) Get(_ context.Context, key string) ([]byte, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte(nil), obj.data...), nil
+}
+
+func (s *Memory) Stat(_ context.Context, key string) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	obj, ok := s.objects[key]
+	if !ok {
+		return ObjectInfo{}, ErrNotFound
+	}
+
+	return ObjectInfo{
+		Size:    int64(len(obj.data)),
+		ModTime: obj.modTime,
+	}, nil
+}
+
+func (s *Memory) List(_ context.Context) ([]ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	objects := make([]ObjectInfo, 0, len(s.objects))
+	for key, obj := range s.objects {
+		objects = append(objects, ObjectInfo{
+			Key:     key,
+			Size:    int64(len(obj.data)),
+			ModTime: obj.modTime,
+		})
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		return objects[i].Key < objects[j].Key
+	})
+
+	return objects, nil
+}
+
+func (s *Memory) Put(_ context.Context, key string, data []byte, _ string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[key] = memoryObject{
+		data:    append([]byte(nil), data...),
+		modTime: time.Now(),
+	}
+
+	return nil
+}
+
+func (s *Memory) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, key)
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3 хранит объекты в бакете Yandex Object Storage или другом S3-совместимом хранилище.
+// Ключи доступа берутся из AWS_ACCESS_KEY_ID и AWS_SECRET_ACCESS_KEY
+type S3 struct {
+	bucket string
+	client *s3.Client
+}
+
+func NewS3(ctx context.Context, bucket, endpoint, region string) (*S3, error) {
+	if bucket == "" {
+		return nil, errors.New("s3 bucket is not set")
+	}
+	if endpoint == "" {
+		endpoint = defaultS3Endpoint
+	}
+	if region == "" {
+		region = defaultS3Region
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load s3 config: %w", err)
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+		o.UsePathStyle = true
+	})
+
+	return &S3{
+		bucket: bucket,
+		client: client,
+	}, nil
+}
+
+func (s *S3) Get(ctx context.Context, key string) ([]byte, error) {
+	if err := validKey(key); err != nil {
+		return nil, err
+	}
+
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var noKey *types.NoSuchKey
+		if errors.As(err, &noKey) {
+			return nil, ErrNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get %s/%s: %w", s.bucket, key, err)
+	}
+	defer out.Body.Close()
+
+	return io.ReadAll(out.Body)
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (ObjectInfo, error) {
+	if err := validKey(key); err != nil {
+		return ObjectInfo{}, err
+	}
+
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var notFound *types.NotFound
+		if errors.As(err, &notFound) {
+			return ObjectInfo{}, ErrNotFound
+		}
+
+		return ObjectInfo{}, fmt.Errorf("failed to stat %s/%s: %w", s.bucket, key, err)
+	}
+
+	info := ObjectInfo{
+		Size: aws.ToInt64(out.ContentLength),
+	}
+	if out.LastModified != nil {
+		info.ModTime = *out.LastModified
+	}
+
+	return info, nil
+}
+
+func (s *S3) List(ctx context.Context) ([]ObjectInfo, error) {
+	var objects []ObjectInfo
+
+	pages := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", s.bucket, err)
+		}
+
+		for _, obj := range page.Contents {
+			info := ObjectInfo{
+				Key:  aws.ToString(obj.Key),
+				Size: aws.ToInt64(obj.Size),
+			}
+			if obj.LastModified != nil {
+				info.ModTime = *obj.LastModified
+			}
+			objects = append(objects, info)
+		}
+	}
+
+	return objects, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	in := &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	}
+	if contentType != "" {
+		in.ContentType = aws.String(contentType)
+	}
+
+	if _, err := s.client.PutObject(ctx, in); err != nil {
+		return fmt.Errorf("failed to put %s/%s: %w", s.bucket, key, err)
+	}
+
+	return nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s/%s: %w", s.bucket, key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var ErrNotFound = errors.New("object not found")
+
+const (
+	KindFS     = "fs"
+	KindS3     = "s3"
+	KindMemory = "memory"
+)
+
+// Имена хранилищ совпадают с mount_point_name бакетов в main.tf
+const (
+	Images = "images"
+	Faces  = "faces"
+)
+
+const (
+	defaultDir        = "/function/storage"
+	defaultS3Endpoint = "https://storage.yandexcloud.net"
+	defaultS3Region   = "ru-central1"
+)
+
+// ObjectInfo - метаданные объекта без его содержимого
+type ObjectInfo struct {
+	// Key заполняется только List
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore - объекты одного бакета по ключу
+type BlobStore interface {
+	// Get возвращает содержимое объекта или ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Stat возвращает метаданные объекта или ErrNotFound
+	Stat(ctx context.Context, key string) (ObjectInfo, error)
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// List возвращает все объекты хранилища по возрастанию ключа
+	List(ctx context.Context) ([]ObjectInfo, error)
+	// Delete не считает ошибкой отсутствие объекта
+	Delete(ctx context.Context, key string) error
+}
+
+type Config struct {
+	Kind string
+	// Dir - каталог хранилища для KindFS
+	Dir string
+	// Bucket, Endpoint и Region - бакет Object Storage для KindS3
+	Bucket   string
+	Endpoint string
+	Region   string
+	// Name - имя хранилища в памяти для KindMemory
+	Name string
+}
+
+func New(ctx context.Context, cfg Config) (BlobStore, error) {
+	switch cfg.Kind {
+	case "", KindFS:
+		return NewFS(cfg.Dir), nil
+	case KindS3:
+		return NewS3(ctx, cfg.Bucket, cfg.Endpoint, cfg.Region)
+	case KindMemory:
+		return Shared(cfg.Name), nil
+	default:
+		return nil, fmt.Errorf("unknown storage kind: %s", cfg.Kind)
+	}
+}
+
+// FromEnv создаёт хранилище name (Images или Faces) по переменным окружения:
+// STORAGE - вид хранилища, STORAGE_DIR - корень для fs (по умолчанию точка монтирования
+// бакетов функции), IMAGES_BUCKET / FACES_BUCKET, S3_ENDPOINT и S3_REGION - для s3
+func FromEnv(ctx context.Context, name string) (BlobStore, error) {
+	dir := os.Getenv("STORAGE_DIR")
+	if dir == "" {
+		dir = defaultDir
+	}
+
+	return New(ctx, Config{
+		Kind:     os.Getenv("STORAGE"),
+		Dir:      path.Join(dir, name),
+		Bucket:   os.Getenv(strings.ToUpper(name) + "_BUCKET"),
+		Endpoint: os.Getenv("S3_ENDPOINT"),
+		Region:   os.Getenv("S3_REGION"),
+		Name:     name,
+	})
+}
+
+// validKey не даёт ключу выйти за пределы хранилища
+func validKey(key string) error {
+	if key == "" || strings.Contains(key, "..") || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid object key: %q", key)
+	}
+
+	return nil
+}
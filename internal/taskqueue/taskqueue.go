@@ -0,0 +1,165 @@
+// Package taskqueue - повтор упавших задач и очередь недоставленных для функций,
+// которые получают задачи из Message Queue
+package taskqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/sqs/types"
+)
+
+const (
+	DefaultEndpoint    = "https://message-queue.api.cloud.yandex.net"
+	defaultMaxAttempts = 3
+
+	// задержка повтора растёт на retryDelayStep с каждой попыткой, но не больше
+	// предела DelaySeconds в Message Queue
+	retryDelayStep = 30 * time.Second
+	maxRetryDelay  = 15 * time.Minute
+)
+
+// Атрибуты сообщения в очереди недоставленных задач, их читает cmd/dlq
+const (
+	attrError     = "error"
+	attrAttempts  = "attempts"
+	attrFailedAt  = "failed_at"
+	attrMessageID = "message_id"
+)
+
+// permanentError - ошибка, которую повтор не исправит: битая задача, удалённое
+// или повреждённое фото. Такие задачи сразу уходят в очередь недоставленных
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+func Permanent(err error) error {
+	return &permanentError{err: err}
+}
+
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// Endpoint - адрес Message Queue; локально SQS_ENDPOINT указывает на очередь в памяти
+func Endpoint() string {
+	if endpoint := os.Getenv("SQS_ENDPOINT"); endpoint != "" {
+		return endpoint
+	}
+
+	return DefaultEndpoint
+}
+
+func NewClient(ctx context.Context) (*sqs.Client, error) {
+	customResolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
+		return aws.Endpoint{
+			URL:           Endpoint(),
+			SigningRegion: "ru-central1",
+		}, nil
+	})
+
+	cfg, err := config.LoadDefaultConfig(
+		ctx,
+		config.WithEndpointResolverWithOptions(customResolver),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load queue config: %w", err)
+	}
+
+	return sqs.NewFromConfig(cfg), nil
+}
+
+// MaxAttempts - сколько раз задача обрабатывается, прежде чем уйти в очередь недоставленных
+func MaxAttempts() int {
+	n, err := strconv.Atoi(os.Getenv("MAX_ATTEMPTS"))
+	if err != nil || n <= 0 {
+		return defaultMaxAttempts
+	}
+
+	return n
+}
+
+func retryDelay(attempt int) time.Duration {
+	delay := time.Duration(attempt) * retryDelayStep
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+
+	return delay
+}
+
+// Retry ставит задачу body в queueURL с задержкой, которая растёт с номером попытки attempt.
+// Повторяется только это сообщение: остальная пачка уже обработана и удаляется триггером
+func Retry(ctx context.Context, client *sqs.Client, queueURL string, body []byte, attempt int) error {
+	msg := string(body)
+
+	_, err := client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:     &queueURL,
+		MessageBody:  &msg,
+		DelaySeconds: int32(retryDelay(attempt) / time.Second),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to requeue task: %w", err)
+	}
+
+	return nil
+}
+
+// DeadLetter пересылает исходное тело сообщения в DLQ_URL, причина и число попыток -
+// в атрибутах сообщения. Без DLQ_URL задача пишется в лог и отбрасывается: ошибка провалила бы
+// всю пачку, и триггер повторил бы задачи, которые уже поставлены в очередь заново
+func DeadLetter(ctx context.Context, client *sqs.Client, messageID, body string, attempts int, cause error) error {
+	dlqURL := os.Getenv("DLQ_URL")
+	if dlqURL == "" {
+		log.Printf("DLQ_URL is not set, dropping message %s after %d attempts: %v, body: %s", messageID, attempts, cause, body)
+		return nil
+	}
+
+	_, err := client.SendMessage(ctx, &sqs.SendMessageInput{
+		QueueUrl:    &dlqURL,
+		MessageBody: &body,
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			attrError:     stringAttr(cause.Error()),
+			attrAttempts:  numberAttr(attempts),
+			attrFailedAt:  stringAttr(time.Now().UTC().Format(time.RFC3339)),
+			attrMessageID: stringAttr(messageID),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send message to dead-letter queue: %w", err)
+	}
+
+	return nil
+}
+
+func stringAttr(value string) types.MessageAttributeValue {
+	if value == "" {
+		// пустые строковые атрибуты Message Queue не принимает
+		value = "-"
+	}
+
+	return types.MessageAttributeValue{
+		DataType:    aws.String("String"),
+		StringValue: &value,
+	}
+}
+
+func numberAttr(value int) types.MessageAttributeValue {
+	return types.MessageAttributeValue{
+		DataType:    aws.String("Number"),
+		StringValue: aws.String(strconv.Itoa(value)),
+	}
+}
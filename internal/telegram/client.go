@@ -0,0 +1,239 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultBaseURL    = "https://api.telegram.org"
+	defaultMaxRetries = 3
+	defaultBackoff    = 500 * time.Millisecond
+	maxFileSize       = 20 << 20
+)
+
+type apiResponse struct {
+	Ok          bool            `json:"ok"`
+	ErrorCode   int             `json:"error_code,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Result      json.RawMessage `json:"result,omitempty"`
+	Parameters  *struct {
+		RetryAfter int `json:"retry_after,omitempty"`
+	} `json:"parameters,omitempty"`
+}
+
+// Client - клиент Bot API; baseURL можно направить на httptest.Server
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+}
+
+type Option func(*Client)
+
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		if baseURL != "" {
+			c.baseURL = strings.TrimRight(baseURL, "/")
+		}
+	}
+}
+
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+	}
+}
+
+func WithBackoff(backoff time.Duration) Option {
+	return func(c *Client) {
+		c.backoff = backoff
+	}
+}
+
+func New(token string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    DefaultBaseURL,
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// call вызывает метод Bot API и разбирает result в result.
+// 429 повторяется через retry_after, 5xx и ошибки соединения - с экспоненциальной задержкой
+func (c *Client) call(ctx context.Context, method string, req interface{}, result interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	delay := c.backoff
+	for attempt := 0; ; attempt++ {
+		err = c.do(ctx, method, body, result)
+		if err == nil || attempt >= c.maxRetries || !retryable(err) {
+			return err
+		}
+
+		wait := delay
+		var tgErr *Error
+		if errors.As(err, &tgErr) && tgErr.RetryAfter > 0 {
+			wait = time.Duration(tgErr.RetryAfter) * time.Second
+		}
+		delay *= 2
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+func (c *Client) do(ctx context.Context, method string, body []byte, result interface{}) error {
+	url := fmt.Sprintf("%s/bot%s/%s", c.baseURL, c.token, method)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	apiResp := &apiResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(apiResp); err != nil {
+		return &Error{
+			Method:      method,
+			Code:        resp.StatusCode,
+			Description: fmt.Sprintf("invalid response: %v", err),
+		}
+	}
+
+	if !apiResp.Ok {
+		tgErr := &Error{
+			Method:      method,
+			Code:        apiResp.ErrorCode,
+			Description: apiResp.Description,
+		}
+		if tgErr.Code == 0 {
+			tgErr.Code = resp.StatusCode
+		}
+		if apiResp.Parameters != nil {
+			tgErr.RetryAfter = apiResp.Parameters.RetryAfter
+		}
+
+		return tgErr
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(apiResp.Result, result); err != nil {
+		return fmt.Errorf("failed to parse %s result: %w", method, err)
+	}
+
+	return nil
+}
+
+func (c *Client) SendMessage(ctx context.Context, req *SendMessageReq) (*Message, error) {
+	msg := &Message{}
+	if err := c.call(ctx, "sendMessage", req, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (c *Client) SendPhoto(ctx context.Context, req *SendPhotoReq) (*Message, error) {
+	msg := &Message{}
+	if err := c.call(ctx, "sendPhoto", req, msg); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+func (c *Client) SendMediaGroup(ctx context.Context, req *SendMediaGroupReq) ([]Message, error) {
+	var msgs []Message
+	if err := c.call(ctx, "sendMediaGroup", req, &msgs); err != nil {
+		return nil, err
+	}
+
+	return msgs, nil
+}
+
+func (c *Client) EditMessageCaption(ctx context.Context, req *EditMessageCaptionReq) error {
+	return c.call(ctx, "editMessageCaption", req, nil)
+}
+
+func (c *Client) AnswerCallbackQuery(ctx context.Context, req *AnswerCallbackReq) error {
+	return c.call(ctx, "answerCallbackQuery", req, nil)
+}
+
+func (c *Client) GetFile(ctx context.Context, fileID string) (*File, error) {
+	file := &File{}
+	if err := c.call(ctx, "getFile", &GetFileReq{FileID: fileID}, file); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// DownloadFile скачивает файл по file_path из ответа GetFile
+func (c *Client) DownloadFile(ctx context.Context, filePath string) ([]byte, error) {
+	url := fmt.Sprintf("%s/file/bot%s/%s", c.baseURL, c.token, filePath)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, &Error{Method: "download", Code: resp.StatusCode, Description: resp.Status}
+	}
+
+	// лишний байт отличает файл ровно в maxFileSize от обрезанного
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxFileSize {
+		return nil, fmt.Errorf("file %s is larger than %d bytes", filePath, maxFileSize)
+	}
+
+	return data, nil
+}
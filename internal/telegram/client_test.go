@@ -0,0 +1,60 @@
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDownloadFileLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    int
+		wantErr bool
+	}{
+		{"small", 1024, false},
+		{"exactly the limit", maxFileSize, false},
+		{"over the limit", maxFileSize + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write(bytes.Repeat([]byte{'x'}, tt.size))
+			}))
+			defer srv.Close()
+
+			data, err := New("token", WithBaseURL(srv.URL)).DownloadFile(context.Background(), "photos/1.jpg")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DownloadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && len(data) != tt.size {
+				t.Errorf("DownloadFile() returned %d bytes, want %d", len(data), tt.size)
+			}
+		})
+	}
+}
+
+func TestCallDoesNotRepeatSentRequest(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// соединение рвётся после того, как запрос дошёл до сервера
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			_ = conn.Close()
+		}
+	}))
+	defer srv.Close()
+
+	c := New("token", WithBaseURL(srv.URL), WithBackoff(0))
+	if _, err := c.SendMessage(context.Background(), &SendMessageReq{ChatID: 1, Text: "hi"}); err == nil {
+		t.Fatal("SendMessage() error = nil")
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("server got %d requests, want 1", n)
+	}
+}
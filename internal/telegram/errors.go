@@ -0,0 +1,52 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Коды ошибок Bot API (поле error_code)
+const (
+	CodeBadRequest      = 400
+	CodeUnauthorized    = 401
+	CodeForbidden       = 403
+	CodeNotFound        = 404
+	CodeConflict        = 409
+	CodeTooManyRequests = 429
+)
+
+// Error - ответ Bot API с ok=false
+type Error struct {
+	Method      string
+	Code        int
+	Description string
+	// RetryAfter - сколько секунд ждать перед повтором (для CodeTooManyRequests)
+	RetryAfter int
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("telegram %s failed: %d %s", e.Method, e.Code, e.Description)
+}
+
+// IsCode проверяет, что err - ошибка Bot API с кодом code
+func IsCode(err error, code int) bool {
+	var tgErr *Error
+	if !errors.As(err, &tgErr) {
+		return false
+	}
+
+	return tgErr.Code == code
+}
+
+// retryable - можно ли повторить запрос: 429 и 5xx от Bot API или сетевая ошибка до отправки
+// запроса. Запрос, оборвавшийся после отправки, мог уже выполниться, и повтор продублировал бы сообщение
+func retryable(err error) bool {
+	var tgErr *Error
+	if errors.As(err, &tgErr) {
+		return tgErr.Code == CodeTooManyRequests || tgErr.Code >= 500
+	}
+
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
@@ -0,0 +1,37 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"testing"
+)
+
+func TestRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"too many requests", &Error{Code: CodeTooManyRequests, RetryAfter: 1}, true},
+		{"server error", &Error{Code: 502}, true},
+		{"bad request", &Error{Code: CodeBadRequest}, false},
+		{"forbidden", fmt.Errorf("send: %w", &Error{Code: CodeForbidden}), false},
+		{"dial", &url.Error{Op: "Post", Err: &net.OpError{Op: "dial", Err: errors.New("connection refused")}}, true},
+		{"read after send", &url.Error{Op: "Post", Err: &net.OpError{Op: "read", Err: errors.New("connection reset")}}, false},
+		{"closed after send", &url.Error{Op: "Post", Err: io.EOF}, false},
+		{"timeout", &url.Error{Op: "Post", Err: context.DeadlineExceeded}, false},
+		{"canceled", context.Canceled, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retryable(tt.err); got != tt.want {
+				t.Errorf("retryable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+module 2hw/telegram
+
+go 1.21.0
@@ -0,0 +1,412 @@
+package telegram
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ParseModeMarkdownV2 = "MarkdownV2"
+
+	// MaxMessageLen - лимит длины текста сообщения в UTF-16 символах
+	MaxMessageLen = 4096
+	// MaxCaptionLen - лимит длины подписи к фото
+	MaxCaptionLen = 1024
+)
+
+const markdownV2Special = "_*[]()~`>#+-=|{}.!\\"
+
+// EscapeMarkdownV2 экранирует произвольный текст (например, имя от пользователя) для parse_mode MarkdownV2
+func EscapeMarkdownV2(s string) string {
+	b := strings.Builder{}
+	b.Grow(len(s))
+
+	for _, r := range s {
+		if strings.ContainsRune(markdownV2Special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// EscapeCode экранирует текст внутри `code` и ```pre``` блоков MarkdownV2
+func EscapeCode(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "`", "\\`").Replace(s)
+}
+
+// Code оборачивает текст в `code` с экранированием
+func Code(s string) string {
+	return "`" + EscapeCode(s) + "`"
+}
+
+// runeLen - длина руны в UTF-16 символах
+func runeLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+
+	return 1
+}
+
+// textLen считает длину так же, как Bot API - в UTF-16 символах
+func textLen(s string) int {
+	n := 0
+	for _, r := range s {
+		n += runeLen(r)
+	}
+
+	return n
+}
+
+// SplitMessage режет текст MarkdownV2 на части не длиннее limit.
+// Режет по строкам, затем по пробелам, в крайнем случае по границе руны, но не внутри
+// экранирования, ссылки [text](url) и строки с языком pre-блока. Незакрытые на границе
+// сущности (*bold*, _italic_, `code`, ```pre``` и т.п.) закрываются в конце части и открываются
+// заново в начале следующей. Пробелы на границе частей отбрасываются, кроме code и pre
+func SplitMessage(text string, limit int) []string {
+	if textLen(text) <= limit {
+		return []string{text}
+	}
+
+	chunks := make([]string, 0)
+	var open []entity
+
+	for text != "" {
+		prefix := reopenEntities(open)
+		if textLen(prefix+text) <= limit {
+			chunks = append(chunks, prefix+text)
+			break
+		}
+
+		// запас под закрытие сущностей
+		budget := limit - textLen(prefix) - maxMarkersLen
+
+		cut := splitPoint(text, budget, codeMarker(open))
+		part := text[:cut]
+		text = text[cut:]
+
+		open = scanEntities(open, part)
+		// закрывающий маркер сразу за разрезом остаётся в этой части вместо добавленного
+		for len(open) > 0 && markerAt(text, 0) == open[len(open)-1].marker {
+			m := open[len(open)-1].marker
+			part += m
+			text = text[len(m):]
+			open = open[:len(open)-1]
+		}
+
+		if codeMarker(open) == "" {
+			part = trimRightUnescaped(part)
+			text = strings.TrimLeft(text, " \n")
+		}
+
+		if text == "" {
+			chunks = append(chunks, prefix+part)
+			break
+		}
+
+		chunks = append(chunks, prefix+part+closeEntities(open))
+	}
+
+	return chunks
+}
+
+// maxMarkersLen - сколько оставить под маркеры сущностей: все виды сразу, открытие и закрытие
+const maxMarkersLen = 2 * len("*_~||__```")
+
+// entity - открытая сущность: marker её закрывает, reopen открывает в следующей части.
+// У pre в reopen входит язык: ```go\n
+type entity struct {
+	marker string
+	reopen string
+}
+
+// splitPoint возвращает байтовую позицию разреза не дальше budget UTF-16 символов;
+// code - маркер code или pre, внутри которого начинается text
+func splitPoint(text string, budget int, code string) int {
+	if budget < 1 {
+		budget = 1
+	}
+
+	end := 0
+	n := 0
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if n+runeLen(r) > budget {
+			break
+		}
+		n += runeLen(r)
+		end += size
+	}
+
+	if end == len(text) {
+		return end
+	}
+
+	cut := end
+	if i := lastUnescaped(text[:end], '\n'); i > 0 {
+		cut = i + 1
+	} else if i := lastUnescaped(text[:end], ' '); i > 0 {
+		cut = i + 1
+	} else {
+		cut = runeCut(text, end)
+	}
+
+	start := atomicStart(text, cut, code)
+	if start == 0 {
+		// ссылка или ```язык в начале text не помещаются в часть, их всё же приходится резать
+		cut = runeCut(text, end)
+		start = atomicStart(text, cut, code)
+	}
+	if start > 0 {
+		cut = start
+	}
+
+	return cut
+}
+
+// runeCut сдвигает разрез end по границе руны назад, чтобы не оторвать экранирующий '\'
+// от символа и не разрезать маркер вроде ``` или ||
+func runeCut(text string, end int) int {
+	cut := end
+	for cut > 1 && isEscapeAt(text, cut-1) {
+		cut--
+	}
+	for cut > 1 && strings.IndexByte("`|_", text[cut]) >= 0 && text[cut-1] == text[cut] {
+		cut--
+	}
+
+	if cut == 0 {
+		_, size := utf8.DecodeRuneInString(text)
+		return size
+	}
+
+	return cut
+}
+
+// lastUnescaped - позиция последнего неэкранированного байта c или -1
+func lastUnescaped(text string, c byte) int {
+	for i := strings.LastIndexByte(text, c); i >= 0; i = strings.LastIndexByte(text[:i], c) {
+		if i == 0 || !isEscapeAt(text, i-1) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// atomicStart возвращает начало ссылки [text](url) или строки ```язык, которую разрезал бы
+// разрез в позиции cut, или -1. Разрез сразу после ```язык оставил бы pre пустым, он тоже не подходит
+func atomicStart(text string, cut int, code string) int {
+	for i := 0; i < cut; {
+		if text[i] == '\\' {
+			i += 2
+			continue
+		}
+
+		switch {
+		case code != "":
+			if strings.HasPrefix(text[i:], code) {
+				i += len(code)
+				code = ""
+				continue
+			}
+		case strings.HasPrefix(text[i:], "```"):
+			end := i + 3 + preLangLen(text[i+3:])
+			if cut <= end {
+				return i
+			}
+			i = end
+			code = "```"
+			continue
+		case strings.HasPrefix(text[i:], "`"):
+			i++
+			code = "`"
+			continue
+		case text[i] == '[':
+			if end := linkEnd(text, i); end > 0 {
+				if cut < end {
+					return i
+				}
+				i = end
+				continue
+			}
+		}
+		i++
+	}
+
+	return -1
+}
+
+// preLangLen - длина строки с языком после открывающего ``` вместе с переводом строки
+func preLangLen(text string) int {
+	nl := strings.IndexByte(text, '\n')
+	if nl < 0 {
+		nl = len(text)
+	} else {
+		nl++
+	}
+
+	// ```code``` в одну строку - pre без языка
+	if i := strings.Index(text, "```"); i >= 0 && i < nl {
+		return 0
+	}
+
+	return nl
+}
+
+// linkEnd возвращает позицию после ссылки [text](url), начатой в start, или -1
+func linkEnd(text string, start int) int {
+	closing := start + 1
+	for ; closing < len(text) && text[closing] != ']'; closing++ {
+		if text[closing] == '\\' {
+			closing++
+		}
+	}
+	if closing+1 >= len(text) || text[closing+1] != '(' {
+		return -1
+	}
+
+	if end := closingParen(text, closing+2); end >= 0 {
+		return end + 1
+	}
+
+	return -1
+}
+
+// closingParen - позиция неэкранированной ')' начиная с from или -1
+func closingParen(text string, from int) int {
+	for i := from; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case ')':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// isEscapeAt проверяет, что в позиции i стоит неэкранированный '\'
+func isEscapeAt(text string, i int) bool {
+	if text[i] != '\\' {
+		return false
+	}
+
+	slashes := 0
+	for j := i; j >= 0 && text[j] == '\\'; j-- {
+		slashes++
+	}
+
+	return slashes%2 == 1
+}
+
+// trimRightUnescaped убирает пробелы и переводы строк в конце, кроме экранированного пробела
+func trimRightUnescaped(part string) string {
+	trimmed := strings.TrimRight(part, " \n")
+	if trimmed != part && trimmed != "" && isEscapeAt(trimmed, len(trimmed)-1) {
+		return part[:len(trimmed)+1]
+	}
+
+	return trimmed
+}
+
+var entityMarkers = []string{"```", "||", "__", "`", "*", "_", "~"}
+
+// scanEntities проходит part и возвращает стек открытых сущностей
+func scanEntities(open []entity, part string) []entity {
+	for i := 0; i < len(part); {
+		if part[i] == '\\' {
+			i += 2
+			continue
+		}
+
+		code := codeMarker(open)
+
+		// в адресе ссылки _ и * - не разметка
+		if code == "" && strings.HasPrefix(part[i:], "](") {
+			if end := closingParen(part, i+2); end >= 0 {
+				i = end + 1
+				continue
+			}
+		}
+
+		matched := markerAt(part, i)
+
+		// внутри code/pre значим только закрывающий маркер
+		if matched == "" || (code != "" && matched != code) {
+			i++
+			continue
+		}
+		i += len(matched)
+
+		if idx := lastIndex(open, matched); idx >= 0 {
+			open = append(open[:idx:idx], open[idx+1:]...)
+			continue
+		}
+
+		e := entity{marker: matched, reopen: matched}
+		if matched == "```" {
+			lang := preLangLen(part[i:])
+			e.reopen = "```" + strings.TrimSuffix(part[i:i+lang], "\n") + "\n"
+			i += lang
+		}
+		open = append(open, e)
+	}
+
+	return open
+}
+
+// markerAt возвращает маркер сущности, который начинается в позиции i, или пустую строку
+func markerAt(text string, i int) string {
+	for _, m := range entityMarkers {
+		if strings.HasPrefix(text[i:], m) {
+			return m
+		}
+	}
+
+	return ""
+}
+
+// codeMarker возвращает маркер code или pre, если он открыт последним, иначе пустую строку
+func codeMarker(open []entity) string {
+	if len(open) == 0 {
+		return ""
+	}
+
+	if m := open[len(open)-1].marker; m == "`" || m == "```" {
+		return m
+	}
+
+	return ""
+}
+
+func reopenEntities(open []entity) string {
+	b := strings.Builder{}
+	for _, e := range open {
+		b.WriteString(e.reopen)
+	}
+
+	return b.String()
+}
+
+func closeEntities(open []entity) string {
+	b := strings.Builder{}
+	for i := len(open) - 1; i >= 0; i-- {
+		b.WriteString(open[i].marker)
+	}
+
+	return b.String()
+}
+
+func lastIndex(open []entity, marker string) int {
+	for i := len(open) - 1; i >= 0; i-- {
+		if open[i].marker == marker {
+			return i
+		}
+	}
+
+	return -1
+}
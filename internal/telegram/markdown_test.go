@@ -0,0 +1,152 @@
+package telegram
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Иван", "Иван"},
+		{"Анна-Мария", `Анна\-Мария`},
+		{"a_b*c", `a\_b\*c`},
+		{`1.5 (x) [y]!`, `1\.5 \(x\) \[y\]\!`},
+		{`back\slash`, `back\\slash`},
+	}
+
+	for _, tt := range tests {
+		if got := EscapeMarkdownV2(tt.in); got != tt.want {
+			t.Errorf("EscapeMarkdownV2(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  []string
+	}{
+		{
+			name:  "fits",
+			text:  "*short*",
+			limit: 10,
+			want:  []string{"*short*"},
+		},
+		{
+			name:  "lines",
+			text:  strings.Repeat("a", 40) + "\n" + strings.Repeat("b", 40),
+			limit: 70,
+			want:  []string{strings.Repeat("a", 40), strings.Repeat("b", 40)},
+		},
+		{
+			name:  "bold reopened",
+			text:  "*" + strings.Repeat("a", 20) + " " + strings.Repeat("b", 20) + "*",
+			limit: 42,
+			want:  []string{"*" + strings.Repeat("a", 20) + "*", "*" + strings.Repeat("b", 20) + "*"},
+		},
+		{
+			name:  "pre keeps language and indentation",
+			text:  "```go\n" + strings.Repeat("x", 20) + "\n    " + strings.Repeat("y", 20) + "\n```",
+			limit: 52,
+			want: []string{
+				"```go\n" + strings.Repeat("x", 20) + "\n```",
+				"```go\n    " + strings.Repeat("y", 20) + "\n```",
+			},
+		},
+		{
+			name:  "pre without language",
+			text:  "```\n" + strings.Repeat("x", 20) + "\n" + strings.Repeat("y", 20) + "```",
+			limit: 46,
+			want: []string{
+				"```\n" + strings.Repeat("x", 20) + "\n```",
+				"```\n" + strings.Repeat("y", 20) + "```",
+			},
+		},
+		{
+			name:  "closing marker is not cut",
+			text:  "```\n" + strings.Repeat("x", 21) + "```" + strings.Repeat("z", 30),
+			limit: 46,
+			want:  []string{"```\n" + strings.Repeat("x", 21) + "```", strings.Repeat("z", 30)},
+		},
+		{
+			name:  "inline code keeps spaces",
+			text:  "`" + strings.Repeat("a", 20) + " " + strings.Repeat("b", 20) + "`",
+			limit: 42,
+			want:  []string{"`" + strings.Repeat("a", 20) + " `", "`" + strings.Repeat("b", 20) + "`"},
+		},
+		{
+			name:  "link is not split",
+			text:  strings.Repeat("a", 20) + " [link text](https://example.com/a_b*c) tail",
+			limit: 60,
+			want:  []string{strings.Repeat("a", 20), "[link text](https://example.com/a_b*c) tail"},
+		},
+		{
+			name:  "underscores in link address are not italic",
+			text:  "[link](https://e.com/a_b) " + strings.Repeat("b", 40),
+			limit: 50,
+			want:  []string{"[link](https://e.com/a_b)", strings.Repeat("b", 40)},
+		},
+		{
+			name:  "pre is not left empty",
+			text:  strings.Repeat("i", 20) + "\n```go\n" + strings.Repeat("x", 30) + "\n```",
+			limit: 50,
+			want:  []string{strings.Repeat("i", 20), "```go\n" + strings.Repeat("x", 30) + "\n```"},
+		},
+		{
+			name:  "escape is not split",
+			text:  strings.Repeat("a", 29) + `\.` + strings.Repeat("b", 29),
+			limit: 50,
+			want:  []string{strings.Repeat("a", 29), `\.` + strings.Repeat("b", 29)},
+		},
+		{
+			name:  "escaped space at the end",
+			text:  strings.Repeat("a", 28) + `\ ` + " " + strings.Repeat("b", 30),
+			limit: 52,
+			want:  []string{strings.Repeat("a", 28) + `\ `, strings.Repeat("b", 30)},
+		},
+		{
+			name:  "surrogate pairs",
+			text:  strings.Repeat("😀", 30),
+			limit: 41,
+			want:  []string{strings.Repeat("😀", 10), strings.Repeat("😀", 20)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SplitMessage(tt.text, tt.limit)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SplitMessage() = %q, want %q", got, tt.want)
+			}
+
+			for _, chunk := range got {
+				if textLen(chunk) > tt.limit {
+					t.Errorf("chunk %q is longer than %d", chunk, tt.limit)
+				}
+				if !utf8.ValidString(chunk) {
+					t.Errorf("chunk %q is not valid UTF-8", chunk)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitMessageMultibyteLimit(t *testing.T) {
+	text := strings.Repeat("ж😀", 3000)
+
+	for _, chunk := range SplitMessage(text, MaxMessageLen) {
+		if n := textLen(chunk); n > MaxMessageLen {
+			t.Fatalf("chunk is %d UTF-16 characters long", n)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Fatal("chunk is not valid UTF-8")
+		}
+	}
+}
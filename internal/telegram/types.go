@@ -0,0 +1,102 @@
+package telegram
+
+// Update - входящее обновление вебхука
+type Update struct {
+	UpdateID      int64          `json:"update_id"`
+	Message       Message        `json:"message"`
+	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+}
+
+type Photo struct {
+	ID       string `json:"file_id"`
+	UniqueID string `json:"file_unique_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+}
+
+type Document struct {
+	ID       string `json:"file_id"`
+	UniqueID string `json:"file_unique_id"`
+	FileName string `json:"file_name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+}
+
+type Message struct {
+	ID   int64 `json:"message_id"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+	Text     string    `json:"text"`
+	Caption  string    `json:"caption,omitempty"`
+	Photo    []Photo   `json:"photo,omitempty"`
+	Document *Document `json:"document,omitempty"`
+	ReplyTo  *Message  `json:"reply_to_message,omitempty"`
+}
+
+type CallbackQuery struct {
+	ID      string   `json:"id"`
+	Message *Message `json:"message,omitempty"`
+	Data    string   `json:"data"`
+}
+
+type File struct {
+	ID       string `json:"file_id"`
+	UniqueID string `json:"file_unique_id"`
+	Size     int64  `json:"file_size,omitempty"`
+	Path     string `json:"file_path"`
+}
+
+type InlineKeyboardButton struct {
+	Text         string `json:"text"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+type InlineKeyboardMarkup struct {
+	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
+}
+
+type InputMediaPhoto struct {
+	Type      string `json:"type"`
+	Media     string `json:"media"`
+	Caption   string `json:"caption,omitempty"`
+	ParseMode string `json:"parse_mode,omitempty"`
+}
+
+type SendMessageReq struct {
+	ChatID           int64                 `json:"chat_id"`
+	Text             string                `json:"text"`
+	ReplyToMessageID int64                 `json:"reply_to_message_id,omitempty"`
+	ParseMode        string                `json:"parse_mode,omitempty"`
+	ReplyMarkup      *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type SendPhotoReq struct {
+	ChatID           int64                 `json:"chat_id"`
+	Photo            string                `json:"photo"`
+	Caption          string                `json:"caption,omitempty"`
+	ParseMode        string                `json:"parse_mode,omitempty"`
+	ReplyToMessageID int64                 `json:"reply_to_message_id,omitempty"`
+	ReplyMarkup      *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type SendMediaGroupReq struct {
+	ChatID int64             `json:"chat_id"`
+	Media  []InputMediaPhoto `json:"media"`
+}
+
+type EditMessageCaptionReq struct {
+	ChatID      int64                 `json:"chat_id"`
+	MessageID   int64                 `json:"message_id"`
+	Caption     string                `json:"caption"`
+	ParseMode   string                `json:"parse_mode,omitempty"`
+	ReplyMarkup *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type AnswerCallbackReq struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+}
+
+type GetFileReq struct {
+	FileID string `json:"file_id"`
+}
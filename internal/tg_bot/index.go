@@ -1,22 +1,18 @@
 package main
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/ydb-platform/ydb-go-sdk/v3"
-	"github.com/ydb-platform/ydb-go-sdk/v3/query"
-	"github.com/ydb-platform/ydb-go-sdk/v3/table"
-	"github.com/ydb-platform/ydb-go-sdk/v3/table/types"
-	yc "github.com/ydb-platform/ydb-go-yc-metadata"
-	"io"
 	"log"
-	"net/http"
 	"os"
-	"path"
+	"strconv"
 	"strings"
+
+	"2hw/repository"
+	"2hw/storage"
+	"2hw/telegram"
 )
 
 // Структура запроса API Gateway v1
@@ -53,102 +49,101 @@ type APIGatewayResponse struct {
 	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
 }
 
-type Photo struct {
-	ID       string `json:"file_id"`
-	UniqueID string `json:"file_unique_id"`
-	Width    int    `json:"width"`
-	Height   int    `json:"height"`
-}
+const (
+	ocrURL              = "https://ocr.api.cloud.yandex.net/ocr/v1/recognizeText"
+	catalog             = "b1g163vdicpkeevao9ga"
+	yaGPTURL            = "https://llm.api.cloud.yandex.net/foundationModels/v1/completion"
+	maxAlbumSize        = 10
+	defaultFindPageSize = 30
+	maxCallbackDataLen  = 64
+	findCallbackPrefix  = "find:"
+	faceCallbackPrefix  = "face:"
+	defaultQuickNames   = 4
+)
 
-type Request struct {
-	UpdateID int64   `json:"update_id"`
-	Message  Message `json:"message"`
-}
+var stores = map[string]storage.BlobStore{}
 
-type Message struct {
-	ID   int64 `json:"message_id"`
-	Chat struct {
-		ID int64 `json:"id"`
-	} `json:"chat"`
-	Text    string   `json:"text"`
-	Photo   []Photo  `json:"photo,omitempty"`
-	ReplyTo *Message `json:"reply_to_message,omitempty"`
-}
+// getStore открывает хранилище name (storage.Images или storage.Faces) один раз на инстанс функции
+func getStore(ctx context.Context, name string) (storage.BlobStore, error) {
+	if store, ok := stores[name]; ok {
+		return store, nil
+	}
 
-type SendMsgReq struct {
-	ChatId           int64  `json:"chat_id"`
-	Text             string `json:"text"`
-	ReplyToMessageId int64  `json:"reply_to_message_id"`
-	ParseMode        string `json:"parse_mode,omitempty"`
+	store, err := storage.FromEnv(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s storage: %w", name, err)
+	}
+	stores[name] = store
+
+	return store, nil
 }
 
-type SendPhotoReq struct {
-	ChatId int64  `json:"chat_id"`
-	Photo  string `json:"photo"`
+var bot *telegram.Client
+
+// getBot создаёт клиент Bot API один раз на инстанс функции
+func getBot() *telegram.Client {
+	if bot == nil {
+		bot = telegram.New(os.Getenv("TG_API_KEY"), telegram.WithBaseURL(os.Getenv("TG_API_URL")))
+	}
+
+	return bot
 }
 
 const (
-	getFilePathURLPattern  = "https://api.telegram.org/bot%s/getFile?file_id=%s"
-	sendMsgURLPattern      = "https://api.telegram.org/bot%s/sendMessage"
-	sendPhotoURLPattern    = "https://api.telegram.org/bot%s/sendPhoto"
-	downloadFileURLPattern = "https://api.telegram.org/file/bot%s"
-	localPath              = "/function/storage/images"
-	ocrURL                 = "https://ocr.api.cloud.yandex.net/ocr/v1/recognizeText"
-	catalog                = "b1g163vdicpkeevao9ga"
-	yaGPTURL               = "https://llm.api.cloud.yandex.net/foundationModels/v1/completion"
-	maxMessageLen          = 4096
-	gwPattern              = "https://%s/?face=%s"
-	gwImagePattern         = "https://%s/?image=%s"
+	actionSkip    = "skip"
+	actionNotFace = "notface"
+	actionDelete  = "delete"
+	actionName    = "name"
 )
 
 func Handler(ctx context.Context, event *APIGatewayRequest) (*APIGatewayResponse, error) {
 	log.Print("received message")
 
-	ydbURL := os.Getenv("YDB_URL")
-	db, err := ydb.Open(ctx,
-		ydbURL,
-		yc.WithInternalCA(),
-		yc.WithCredentials(),
-	)
+	repo, closeRepo, err := repository.Open(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to init db connection: %v", err)
+		return nil, err
 	}
-	defer func() {
-		_ = db.Close(ctx)
-	}()
+	defer closeRepo()
 
-	req := &Request{}
+	req := &telegram.Update{}
 
 	if err := json.Unmarshal([]byte(event.Body), &req); err != nil {
 		return nil, fmt.Errorf("an error has occurred when parsing body: %w", err)
 	}
 
+	if req.CallbackQuery != nil {
+		if err := handleCallback(ctx, repo, req.CallbackQuery); err != nil {
+			return nil, fmt.Errorf("failed to handle callback: %w", err)
+		}
+
+		return &APIGatewayResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
 	//log.Println(event)
-	if req.Message.ReplyTo != nil {
-		faceID, err := read(ctx, db.Query())
+	if req.Message.ReplyTo != nil && req.Message.Text != "" {
+		faceID, err := repo.FindSentFace(ctx, req.Message.Chat.ID, req.Message.ReplyTo.ID)
+		if errors.Is(err, repository.ErrNotFound) {
+			if err := sendReply(ctx, req.Message.Chat.ID, "Ответьте именем на фото, присланное командой /getface", req.Message.ID); err != nil {
+				return nil, fmt.Errorf("failed to send reply: %w", err)
+			}
+
+			return &APIGatewayResponse{
+				StatusCode: 200,
+			}, nil
+		}
 		if err != nil {
 			return nil, fmt.Errorf("failed to read face id: %v", err)
 		}
 
-		namesPath := "names"
-		namesPath = path.Join(db.Name(), namesPath)
-
-		err = db.Table().BulkUpsert(ctx,
-			namesPath,
-			table.BulkUpsertDataRows(
-				types.ListValue(
-					types.StructValue(
-						types.StructFieldValue("FaceID", types.StringValueFromString(faceID)),
-						types.StructFieldValue("FaceName", types.StringValueFromString(req.Message.Text)),
-					))),
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to BulkInsert to names: %v", err)
+		if err := repo.NameFace(ctx, faceID, req.Message.Text); err != nil {
+			return nil, fmt.Errorf("failed to name face: %v", err)
 		}
 
-		answer := fmt.Sprintf("Лицу с ID: `%s` присвоено имя `%s`", faceID, req.Message.Text)
+		answer := fmt.Sprintf("Лицу с ID: %s присвоено имя %s", telegram.Code(faceID), telegram.Code(req.Message.Text))
 
-		if err := sendReply(req.Message.Chat.ID, answer, req.Message.ID); err != nil {
+		if err := sendMessage(ctx, req.Message.Chat.ID, answer, req.Message.ID, nil); err != nil {
 			return nil, fmt.Errorf("failed to send reply: %w", err)
 		}
 
@@ -157,8 +152,18 @@ func Handler(ctx context.Context, event *APIGatewayRequest) (*APIGatewayResponse
 		}, nil
 	}
 
+	if fileID, ok := uploadFileID(&req.Message); ok {
+		if err := handleUpload(ctx, repo, &req.Message, fileID); err != nil {
+			return nil, fmt.Errorf("failed to handle upload: %w", err)
+		}
+
+		return &APIGatewayResponse{
+			StatusCode: 200,
+		}, nil
+	}
+
 	if req.Message.Text == "" {
-		if err := sendReply(req.Message.Chat.ID, "Ошибка", req.Message.ID); err != nil {
+		if err := sendReply(ctx, req.Message.Chat.ID, "Ошибка", req.Message.ID); err != nil {
 			return nil, fmt.Errorf("failed to send reply: %w", err)
 		}
 
@@ -167,18 +172,19 @@ func Handler(ctx context.Context, event *APIGatewayRequest) (*APIGatewayResponse
 		}, nil
 	}
 
-	cmds := strings.Split(req.Message.Text, " ")
+	cmd, arg, _ := strings.Cut(strings.TrimSpace(req.Message.Text), " ")
+	arg = strings.TrimSpace(arg)
 
-	switch cmds[0] {
+	switch cmd {
 	case "/getface":
-		if err := handleGetFace(ctx, db, req.Message.Chat.ID); err != nil {
-			if err := sendReply(req.Message.Chat.ID, "Не удалось найти фото без имени", req.Message.ID); err != nil {
+		if err := handleGetFace(ctx, repo, req.Message.Chat.ID); err != nil {
+			if err := sendReply(ctx, req.Message.Chat.ID, "Не удалось найти фото без имени", req.Message.ID); err != nil {
 				return nil, fmt.Errorf("failed to send reply: %w", err)
 			}
 		}
 	case "/find":
-		if len(cmds) < 2 {
-			if err := sendReply(req.Message.Chat.ID, "Ошибка", req.Message.ID); err != nil {
+		if arg == "" {
+			if err := sendReply(ctx, req.Message.Chat.ID, "Ошибка", req.Message.ID); err != nil {
 				return nil, fmt.Errorf("failed to send reply: %w", err)
 			}
 
@@ -186,11 +192,11 @@ func Handler(ctx context.Context, event *APIGatewayRequest) (*APIGatewayResponse
 				StatusCode: 200,
 			}, nil
 		}
-		if err := handleFindName(ctx, db, cmds[1], req.Message.Chat.ID, req.Message.ID); err != nil {
+		if err := handleFindName(ctx, repo, arg, 0, req.Message.Chat.ID, req.Message.ID); err != nil {
 			return nil, fmt.Errorf("failed to handle /findname: %v", err)
 		}
 	default:
-		if err := sendReply(req.Message.Chat.ID, "Ошибка", req.Message.ID); err != nil {
+		if err := sendReply(ctx, req.Message.Chat.ID, "Ошибка", req.Message.ID); err != nil {
 			return nil, fmt.Errorf("failed to send reply: %w", err)
 		}
 	}
@@ -200,213 +206,357 @@ func Handler(ctx context.Context, event *APIGatewayRequest) (*APIGatewayResponse
 	}, nil
 }
 
-func sendReply(chatID int64, text string, replyToMsgID int64) error {
-	token := os.Getenv("TG_API_KEY")
+// sendReply отправляет обычный текст, экранируя его для MarkdownV2
+func sendReply(ctx context.Context, chatID int64, text string, replyToMsgID int64) error {
+	return sendMessage(ctx, chatID, telegram.EscapeMarkdownV2(text), replyToMsgID, nil)
+}
 
-	texts := make([]string, 0)
-	if len(text) > maxMessageLen {
-		texts = append(texts, text[:maxMessageLen])
-		texts = append(texts, text[maxMessageLen:])
-	} else {
-		texts = append(texts, text)
-	}
+// sendMessage отправляет текст в MarkdownV2, при необходимости разбивая его на части;
+// клавиатура markup прикрепляется к последней части
+func sendMessage(ctx context.Context, chatID int64, text string, replyToMsgID int64, markup *telegram.InlineKeyboardMarkup) error {
+	texts := telegram.SplitMessage(text, telegram.MaxMessageLen)
 
 	for i := 0; i < len(texts); i++ {
-		sendReplyBody := &SendMsgReq{
-			ChatId:           chatID,
+		req := &telegram.SendMessageReq{
+			ChatID:           chatID,
 			Text:             texts[i],
-			ReplyToMessageId: replyToMsgID,
-			ParseMode:        "Markdown",
+			ReplyToMessageID: replyToMsgID,
+			ParseMode:        telegram.ParseModeMarkdownV2,
 		}
-
-		sendReplyBodyBytes, err := json.Marshal(sendReplyBody)
-		if err != nil {
-			return err
+		if i == len(texts)-1 {
+			req.ReplyMarkup = markup
 		}
 
-		resp, err := http.Post(
-			fmt.Sprintf(sendMsgURLPattern, token),
-			"application/json",
-			bytes.NewReader(sendReplyBodyBytes))
-		if err != nil {
+		if _, err := getBot().SendMessage(ctx, req); err != nil {
 			return err
 		}
-
-		if resp.StatusCode >= 300 {
-			body, _ := io.ReadAll(resp.Body)
-			return errors.New("failed to send reply tg message: " + resp.Status + " " + string(body))
-		}
 	}
 
 	return nil
 }
 
-func handleGetFace(ctx context.Context, db *ydb.Driver, chatID int64) error {
-	faceID, err := read(ctx, db.Query())
+func handleGetFace(ctx context.Context, repo repository.Repository, chatID int64) error {
+	face, err := repo.NextUnnamedFace(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to sread face id: %v", err)
 	}
 
-	domain := os.Getenv("API_GW_URL")
-	url := fmt.Sprintf(gwPattern, domain, faceID)
-	if err := sendPhoto(chatID, url); err != nil {
+	caption := ""
+	if face.AutoNamed {
+		caption = "Похоже, это " + telegram.Code(face.FaceName) +
+			telegram.EscapeMarkdownV2(". Ответьте на фото именем, чтобы подтвердить или исправить")
+	}
+
+	markup, err := faceKeyboard(ctx, repo, face)
+	if err != nil {
+		return fmt.Errorf("failed to build keyboard: %v", err)
+	}
+
+	url, err := faceURL(face.FaceID)
+	if err != nil {
+		return fmt.Errorf("failed to sign face url: %v", err)
+	}
+
+	sent, err := sendPhoto(ctx, chatID, url, caption, markup)
+	if err != nil {
 		return fmt.Errorf("failed to send photo: %v", err)
 	}
 
+	if err := repo.SaveSentFace(ctx, chatID, sent.ID, face.FaceID); err != nil {
+		return fmt.Errorf("failed to save sent face: %v", err)
+	}
+
 	return nil
 }
 
-func read(ctx context.Context, c query.Client) (string, error) {
-	faceID := ""
-
-	err := c.Do(ctx,
-		func(ctx context.Context, s query.Session) (err error) {
-			result, err := s.Query(ctx, `
-					SELECT FaceID
-					FROM names
-					WHERE FaceName is NULL
-					LIMIT 1
-				`,
-				query.WithTxControl(query.TxControl(query.BeginTx(query.WithSnapshotReadOnly()))),
-			)
-			if err != nil {
-				return err
-			}
+func quickNamesCount() int {
+	count, err := strconv.Atoi(os.Getenv("QUICK_NAMES"))
+	if err != nil || count < 0 {
+		return defaultQuickNames
+	}
 
-			defer func() {
-				_ = result.Close(ctx)
-			}()
+	return count
+}
 
-			for {
-				set, err := result.NextResultSet(ctx)
-				if err != nil {
-					if errors.Is(err, io.EOF) {
-						break
-					}
+// faceKeyboard строит клавиатуру под фото лица: быстрый выбор частых имён и действия над лицом.
+// Само лицо определяется по сообщению через sent_faces, поэтому в callback_data только действие
+func faceKeyboard(ctx context.Context, repo repository.Repository, face repository.Face) (*telegram.InlineKeyboardMarkup, error) {
+	names, err := repo.TopNames(ctx, quickNamesCount())
+	if err != nil {
+		return nil, err
+	}
 
-					return err
-				}
+	if face.AutoNamed {
+		names = append([]string{face.FaceName}, names...)
+	}
 
-				row, err := set.NextRow(ctx)
-				if err != nil {
-					return err
-				}
+	rows := make([][]telegram.InlineKeyboardButton, 0)
+	row := make([]telegram.InlineKeyboardButton, 0, 2)
+	seen := make(map[string]bool)
+	for _, name := range names {
+		data := faceCallbackPrefix + actionName + ":" + name
+		if seen[name] || len(data) > maxCallbackDataLen {
+			continue
+		}
+		seen[name] = true
 
-				if err := row.Scan(&faceID); err != nil {
-					return err
-				}
-			}
+		row = append(row, telegram.InlineKeyboardButton{Text: name, CallbackData: data})
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = make([]telegram.InlineKeyboardButton, 0, 2)
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
 
-			return nil
-		},
-	)
+	rows = append(rows, []telegram.InlineKeyboardButton{
+		{Text: "Пропустить", CallbackData: faceCallbackPrefix + actionSkip},
+		{Text: "Не лицо", CallbackData: faceCallbackPrefix + actionNotFace},
+		{Text: "Удалить", CallbackData: faceCallbackPrefix + actionDelete},
+	})
 
-	return faceID, err
+	return &telegram.InlineKeyboardMarkup{InlineKeyboard: rows}, nil
 }
 
-func handleFindName(ctx context.Context, db *ydb.Driver, name string, chatID int64, replyTo int64) error {
-	images, err := findByName(ctx, db.Query(), name)
+// handleFaceAction выполняет действие с кнопки под фото и заменяет подпись фото результатом
+func handleFaceAction(ctx context.Context, repo repository.Repository, cb *telegram.CallbackQuery) error {
+	chatID := cb.Message.Chat.ID
+	action, arg, _ := strings.Cut(strings.TrimPrefix(cb.Data, faceCallbackPrefix), ":")
+
+	faceID, err := repo.FindSentFace(ctx, chatID, cb.Message.ID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return answerCallbackQuery(ctx, cb.ID, "Лицо не найдено")
+	}
 	if err != nil {
-		return fmt.Errorf("failed to retrieve images %v", err)
+		return fmt.Errorf("failed to read face id: %v", err)
 	}
 
-	if len(images) == 0 {
-		return sendReply(chatID, fmt.Sprintf("Фотографии с %s не найдены", name), replyTo)
+	notice := ""
+	caption := ""
+	switch action {
+	case actionSkip:
+		if err := repo.SkipFace(ctx, faceID); err != nil {
+			return fmt.Errorf("failed to skip face: %v", err)
+		}
+		notice = "Пропущено"
+		caption = telegram.EscapeMarkdownV2(notice)
+	case actionNotFace:
+		if err := repo.MarkNotFace(ctx, faceID); err != nil {
+			return fmt.Errorf("failed to mark face: %v", err)
+		}
+		notice = "Отмечено: не лицо"
+		caption = telegram.EscapeMarkdownV2(notice)
+	case actionDelete:
+		if err := repo.DeleteFace(ctx, faceID); err != nil {
+			return fmt.Errorf("failed to delete face: %v", err)
+		}
+		faces, err := getStore(ctx, storage.Faces)
+		if err != nil {
+			return err
+		}
+		if err := faces.Delete(ctx, faceID); err != nil {
+			log.Printf("failed to remove face file %s: %v", faceID, err)
+		}
+		notice = "Удалено"
+		caption = telegram.EscapeMarkdownV2(notice)
+	case actionName:
+		if arg == "" {
+			return fmt.Errorf("empty name in callback data: %s", cb.Data)
+		}
+		if err := repo.NameFace(ctx, faceID, arg); err != nil {
+			return fmt.Errorf("failed to name face: %v", err)
+		}
+		notice = "Присвоено имя " + arg
+		caption = "Присвоено имя " + telegram.Code(arg)
+	default:
+		return fmt.Errorf("unknown face action: %s", action)
+	}
+
+	if err := answerCallbackQuery(ctx, cb.ID, notice); err != nil {
+		return fmt.Errorf("failed to answer callback: %w", err)
+	}
+
+	if err := editMessageCaption(ctx, chatID, cb.Message.ID, caption); err != nil {
+		return fmt.Errorf("failed to edit message: %w", err)
 	}
 
-	domain := os.Getenv("API_GW_URL")
-	for _, imageName := range images {
-		if err := sendPhoto(chatID, fmt.Sprintf(gwImagePattern, domain, imageName)); err != nil {
-			return fmt.Errorf("failed to send photo: %v", err)
+	if action == actionSkip {
+		if err := handleGetFace(ctx, repo, chatID); err != nil {
+			return sendReply(ctx, chatID, "Не удалось найти фото без имени", 0)
 		}
 	}
 
 	return nil
 }
 
-var (
-	selectByName = `SELECT    r.ImageID as image
-				 FROM      (SELECT FaceID FROM names WHERE FaceName = '%s') AS n
-				 INNER  JOIN relations AS r ON n.FaceID = r.FaceID;`
-)
+func findPageSize() int {
+	size, err := strconv.Atoi(os.Getenv("FIND_PAGE_SIZE"))
+	if err != nil || size <= 0 {
+		return defaultFindPageSize
+	}
+
+	return size
+}
+
+func handleFindName(ctx context.Context, repo repository.Repository, name string, page int, chatID int64, replyTo int64) error {
+	names, err := repo.ListNames(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list names %v", err)
+	}
+
+	exact, similar := matchNames(name, names)
+	if len(exact) == 0 {
+		if len(similar) == 0 {
+			return sendReply(ctx, chatID, fmt.Sprintf("Фотографии с %s не найдены", name), replyTo)
+		}
+
+		return sendReply(ctx, chatID, fmt.Sprintf("Фотографии с %s не найдены. Возможно, вы имели в виду: %s",
+			name, strings.Join(similar, ", ")), replyTo)
+	}
+
+	images := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, n := range exact {
+		found, err := repo.FindImagesByName(ctx, n)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve images %v", err)
+		}
+
+		for _, img := range found {
+			if !seen[img] {
+				seen[img] = true
+				images = append(images, img)
+			}
+		}
+	}
 
-func findByName(ctx context.Context, c query.Client, name string) ([]string, error) {
-	var images []string
+	if len(images) == 0 {
+		return sendReply(ctx, chatID, fmt.Sprintf("Фотографии с %s не найдены", name), replyTo)
+	}
 
-	err := c.Do(ctx,
-		func(ctx context.Context, s query.Session) (err error) {
-			result, err := s.Query(ctx, fmt.Sprintf(selectByName, name),
-				query.WithTxControl(query.TxControl(query.BeginTx(query.WithSnapshotReadOnly()))),
-			)
+	pageSize := findPageSize()
+	from := page * pageSize
+	if from >= len(images) {
+		return sendReply(ctx, chatID, "Больше фотографий нет", replyTo)
+	}
+	to := min(from+pageSize, len(images))
+
+	for start := from; start < to; start += maxAlbumSize {
+		end := min(start+maxAlbumSize, to)
+		caption := fmt.Sprintf("%s: фото %d–%d из %d", exact[0], start+1, end, len(images))
+
+		urls := make([]string, 0, end-start)
+		for _, imageName := range images[start:end] {
+			url, err := imageURL(imageName)
 			if err != nil {
-				return fmt.Errorf("failed to exec query %v", err)
+				return fmt.Errorf("failed to sign image url: %v", err)
 			}
+			urls = append(urls, url)
+		}
 
-			defer func() {
-				_ = result.Close(ctx)
-			}()
-
-			img := ""
-			for {
-				set, err := result.NextResultSet(ctx)
-				if err != nil {
-					if errors.Is(err, io.EOF) {
-						break
-					}
-
-					return err
-				}
-
-				for {
-					row, err := set.NextRow(ctx)
-					if err != nil {
-						if errors.Is(err, io.EOF) {
-							break
-						}
-
-						return fmt.Errorf("read rows error: %v", err)
-					}
-
-					if err := row.Scan(&img); err != nil {
-						return err
-					}
-
-					images = append(images, img)
-				}
+		if end-start == 1 {
+			if _, err := sendPhoto(ctx, chatID, urls[0], telegram.EscapeMarkdownV2(caption), nil); err != nil {
+				return fmt.Errorf("failed to send photo: %v", err)
 			}
 
-			return nil
-		},
-	)
+			continue
+		}
 
-	return images, err
-}
+		media := make([]telegram.InputMediaPhoto, 0, end-start)
+		for _, url := range urls {
+			media = append(media, telegram.InputMediaPhoto{
+				Type:  "photo",
+				Media: url,
+			})
+		}
+		media[0].Caption = caption
 
-func sendPhoto(chatID int64, photoURL string) error {
-	token := os.Getenv("TG_API_KEY")
+		if err := sendMediaGroup(ctx, chatID, media); err != nil {
+			return fmt.Errorf("failed to send media group: %v", err)
+		}
+	}
 
-	sendPhotoBody := &SendPhotoReq{
-		ChatId: chatID,
-		Photo:  photoURL,
+	if to == len(images) {
+		return nil
 	}
 
-	sendPhotoBodyBytes, err := json.Marshal(sendPhotoBody)
-	if err != nil {
-		return err
+	data := fmt.Sprintf("%s%d:%s", findCallbackPrefix, page+1, name)
+	if len(data) > maxCallbackDataLen {
+		return sendReply(ctx, chatID, fmt.Sprintf("Показано %d из %d фотографий", to, len(images)), replyTo)
 	}
 
-	resp, err := http.Post(
-		fmt.Sprintf(sendPhotoURLPattern, token),
-		"application/json",
-		bytes.NewReader(sendPhotoBodyBytes))
-	if err != nil {
-		return err
+	return sendMessage(ctx, chatID, telegram.EscapeMarkdownV2(fmt.Sprintf("Показано %d из %d фотографий", to, len(images))), replyTo,
+		&telegram.InlineKeyboardMarkup{
+			InlineKeyboard: [][]telegram.InlineKeyboardButton{{
+				{Text: "Следующая страница", CallbackData: data},
+			}},
+		})
+}
+
+func handleCallback(ctx context.Context, repo repository.Repository, cb *telegram.CallbackQuery) error {
+	if cb.Message == nil {
+		return answerCallbackQuery(ctx, cb.ID, "")
 	}
+	chatID := cb.Message.Chat.ID
+
+	switch {
+	case strings.HasPrefix(cb.Data, faceCallbackPrefix):
+		return handleFaceAction(ctx, repo, cb)
+	case strings.HasPrefix(cb.Data, findCallbackPrefix):
+		if err := answerCallbackQuery(ctx, cb.ID, ""); err != nil {
+			return fmt.Errorf("failed to answer callback: %w", err)
+		}
+
+		pageStr, name, ok := strings.Cut(strings.TrimPrefix(cb.Data, findCallbackPrefix), ":")
+		page, err := strconv.Atoi(pageStr)
+		if !ok || err != nil {
+			return fmt.Errorf("invalid callback data: %s", cb.Data)
+		}
 
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(resp.Body)
-		return errors.New("failed to send photo: " + resp.Status + " " + string(body))
+		return handleFindName(ctx, repo, name, page, chatID, 0)
+	default:
+		return answerCallbackQuery(ctx, cb.ID, "Неизвестное действие")
 	}
+}
 
-	return nil
+// sendPhoto отправляет фото; caption должен быть в MarkdownV2
+func sendPhoto(ctx context.Context, chatID int64, photoURL string, caption string, markup *telegram.InlineKeyboardMarkup) (*telegram.Message, error) {
+	req := &telegram.SendPhotoReq{
+		ChatID:      chatID,
+		Photo:       photoURL,
+		ReplyMarkup: markup,
+	}
+	if caption != "" {
+		req.Caption = caption
+		req.ParseMode = telegram.ParseModeMarkdownV2
+	}
+
+	return getBot().SendPhoto(ctx, req)
+}
+
+func sendMediaGroup(ctx context.Context, chatID int64, media []telegram.InputMediaPhoto) error {
+	_, err := getBot().SendMediaGroup(ctx, &telegram.SendMediaGroupReq{
+		ChatID: chatID,
+		Media:  media,
+	})
+
+	return err
+}
+
+func answerCallbackQuery(ctx context.Context, callbackID string, text string) error {
+	return getBot().AnswerCallbackQuery(ctx, &telegram.AnswerCallbackReq{
+		CallbackQueryID: callbackID,
+		Text:            text,
+	})
+}
+
+// editMessageCaption меняет подпись фото и убирает клавиатуру
+func editMessageCaption(ctx context.Context, chatID int64, messageID int64, caption string) error {
+	return getBot().EditMessageCaption(ctx, &telegram.EditMessageCaptionReq{
+		ChatID:    chatID,
+		MessageID: messageID,
+		Caption:   caption,
+		ParseMode: telegram.ParseModeMarkdownV2,
+	})
 }
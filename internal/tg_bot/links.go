@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/url"
+	"os"
+
+	"2hw/signurl"
+)
+
+// thumbSize - Telegram всё равно сжимает фото до 1280 точек, поэтому оригиналы не скачиваются
+const thumbSize = "1280"
+
+var signer *signurl.Signer
+
+// getSigner читает ключ подписи ссылок один раз на инстанс функции
+func getSigner() (*signurl.Signer, error) {
+	if signer != nil {
+		return signer, nil
+	}
+
+	s, err := signurl.FromEnv()
+	if err != nil {
+		return nil, err
+	}
+	signer = s
+
+	return signer, nil
+}
+
+// faceURL - подписанная ссылка на вырезанное лицо в API Gateway
+func faceURL(faceID string) (string, error) {
+	s, err := getSigner()
+	if err != nil {
+		return "", err
+	}
+
+	return s.URL(os.Getenv("API_GW_URL"), url.Values{"face": {faceID}}), nil
+}
+
+// imageURL - подписанная ссылка на уменьшенное исходное фото
+func imageURL(imageID string) (string, error) {
+	s, err := getSigner()
+	if err != nil {
+		return "", err
+	}
+
+	return s.URL(os.Getenv("API_GW_URL"), url.Values{
+		"image": {imageID},
+		"w":     {thumbSize},
+		"h":     {thumbSize},
+	}), nil
+}
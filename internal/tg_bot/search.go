@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	maxSuggestions = 5
+	maxTypos       = 2
+)
+
+var folder = cases.Fold()
+
+// normalizeName приводит имя к виду для сравнения: NFC, без регистра, ё = е, одиночные пробелы
+func normalizeName(name string) string {
+	name = norm.NFC.String(name)
+	name = folder.String(name)
+	name = strings.ReplaceAll(name, "ё", "е")
+
+	return strings.Join(strings.FieldsFunc(name, unicode.IsSpace), " ")
+}
+
+// matchNames возвращает имена, совпадающие с запросом без учёта регистра,
+// а если таких нет - похожие имена для подсказки "возможно, вы имели в виду"
+func matchNames(query string, names []string) (exact []string, similar []string) {
+	q := normalizeName(query)
+
+	type candidate struct {
+		name string
+		dist int
+	}
+	candidates := make([]candidate, 0)
+
+	for _, name := range names {
+		n := normalizeName(name)
+
+		if n == q {
+			exact = append(exact, name)
+			continue
+		}
+
+		if strings.HasPrefix(n, q) {
+			candidates = append(candidates, candidate{name: name, dist: 0})
+			continue
+		}
+
+		if dist := levenshtein(n, q); dist <= maxTypos {
+			candidates = append(candidates, candidate{name: name, dist: dist})
+		}
+	}
+
+	if len(exact) > 0 {
+		return exact, nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].dist < candidates[j].dist
+	})
+
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		similar = append(similar, candidates[i].name)
+	}
+
+	return nil, similar
+}
+
+func levenshtein(a, b string) int {
+	ra := []rune(a)
+	rb := []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"Иван", "иван"},
+		{"  Анна   Мария\t", "анна мария"},
+		{"Пётр", "петр"},
+		{"ПЁТР", "петр"},
+		// й из и и комбинируемой краткой
+		{"Андрей", "андрей"},
+		{"Straße", "strasse"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeName(tt.in); got != tt.want {
+			t.Errorf("normalizeName(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"иван", "", 4},
+		{"", "иван", 4},
+		{"иван", "иван", 0},
+		{"иван", "иваг", 1},
+		{"иван", "ивна", 2},
+		{"мария", "мари", 1},
+		{"kitten", "sitting", 3},
+	}
+
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := levenshtein(tt.b, tt.a); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestMatchNames(t *testing.T) {
+	names := []string{"Иван Петров", "Иван", "иван", "Ивонна", "Мария", "Марина"}
+
+	tests := []struct {
+		name        string
+		query       string
+		wantExact   []string
+		wantSimilar []string
+	}{
+		{"case-insensitive", "ИВАН", []string{"Иван", "иван"}, nil},
+		{"spaces", " иван   петров ", []string{"Иван Петров"}, nil},
+		{"prefix first", "мари", nil, []string{"Мария", "Марина"}},
+		{"typo", "Мапия", nil, []string{"Мария"}},
+		{"nothing similar", "Александр", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exact, similar := matchNames(tt.query, names)
+			if !reflect.DeepEqual(exact, tt.wantExact) {
+				t.Errorf("exact = %q, want %q", exact, tt.wantExact)
+			}
+			if !reflect.DeepEqual(similar, tt.wantSimilar) {
+				t.Errorf("similar = %q, want %q", similar, tt.wantSimilar)
+			}
+		})
+	}
+}
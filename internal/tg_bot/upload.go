@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"2hw/repository"
+	"2hw/storage"
+	"2hw/telegram"
+)
+
+// uploadFileID возвращает file_id самой большой фотографии или изображения, присланного документом
+func uploadFileID(msg *telegram.Message) (string, bool) {
+	if len(msg.Photo) > 0 {
+		largest := msg.Photo[0]
+		for _, p := range msg.Photo[1:] {
+			if p.Width*p.Height > largest.Width*largest.Height {
+				largest = p
+			}
+		}
+
+		return largest.ID, true
+	}
+
+	if msg.Document != nil && strings.HasPrefix(msg.Document.MimeType, "image/") {
+		return msg.Document.ID, true
+	}
+
+	return "", false
+}
+
+// handleUpload сохраняет фото в бакет images, откуда его заберёт триггер face_detection.
+// Ответ с результатом пришлёт face_cut, когда вырежет все лица (см. таблицу uploads)
+func handleUpload(ctx context.Context, repo repository.Repository, msg *telegram.Message, fileID string) error {
+	data, err := downloadFile(ctx, fileID)
+	if err != nil {
+		return fmt.Errorf("failed to download file: %w", err)
+	}
+
+	jpg, err := toJPEG(data)
+	if err != nil {
+		return sendReply(ctx, msg.Chat.ID, "Не удалось распознать изображение", msg.ID)
+	}
+
+	imageID := uuid.New().String() + ".jpg"
+
+	// запись о загрузке нужна до появления файла в бакете, иначе face_cut может не найти, кому ответить
+	err = repo.SaveUpload(ctx, repository.Upload{
+		ImageID:   imageID,
+		ChatID:    msg.Chat.ID,
+		MessageID: msg.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save upload: %w", err)
+	}
+
+	images, err := getStore(ctx, storage.Images)
+	if err != nil {
+		return err
+	}
+
+	if err := images.Put(ctx, imageID, jpg, "image/jpeg"); err != nil {
+		return fmt.Errorf("failed to save image: %w", err)
+	}
+
+	return sendReply(ctx, msg.Chat.ID, "Фото принято, ищу на нём лица", msg.ID)
+}
+
+func downloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	file, err := getBot().GetFile(ctx, fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	return getBot().DownloadFile(ctx, file.Path)
+}
+
+// toJPEG перекодирует изображение в JPEG: триггер и детектор работают только с .jpg
+func toJPEG(data []byte) ([]byte, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	if format == "jpeg" {
+		return data, nil
+	}
+
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
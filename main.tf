@@ -4,12 +4,14 @@ terraform {
       source = "yandex-cloud/yandex"
     }
   }
-  required_version = ">= 0.13"
+  required_version = ">= 1.7"
 }
 
 locals {
   home = "/Users/kirill"
   queue_name = "vvot14-task"
+  detect_queue_name = "vvot14-detect"
+  dlq_name = "vvot14-task-dlq"
 }
 
 variable "cloud_id" {
@@ -27,6 +29,24 @@ variable "TG_API_KEY" {
   description = "Ключ телеграмм бота"
 }
 
+variable "EDENAI_API_TOKEN" {
+  type = string
+  sensitive = true
+  description = "Токен EdenAI для детектора лиц"
+}
+
+variable "URL_SIGNING_KEY" {
+  type = string
+  sensitive = true
+  description = "Секрет для подписи ссылок на API Gateway"
+}
+
+variable "API_TOKEN" {
+  type = string
+  sensitive = true
+  description = "Токен REST API шлюза (Authorization: Bearer)"
+}
+
 provider "yandex" {
   cloud_id = var.cloud_id
   folder_id = var.folder_id
@@ -73,19 +93,23 @@ resource "yandex_function" "face-detect" {
   execution_timeout  = 15
   environment = {
     "QUEUE_URL" = yandex_message_queue.task_queue.id,
+    "DETECT_QUEUE_URL" = yandex_message_queue.detect_queue.id,
+    "DLQ_URL" = yandex_message_queue.dead_letter_queue.id,
+    "MAX_ATTEMPTS" = "3",
     "AWS_ACCESS_KEY_ID"=yandex_iam_service_account_static_access_key.queue-static-key.access_key
     "AWS_SECRET_ACCESS_KEY"=yandex_iam_service_account_static_access_key.queue-static-key.secret_key,
-    "API_GW_URL" = yandex_api_gateway.api-gateway.domain
+    "API_GW_URL" = yandex_api_gateway.api-gateway.domain,
+    "DETECTOR" = "edenai",
+    "DETECTOR_PROVIDER" = "amazon",
+    "URL_SIGNING_KEY" = var.URL_SIGNING_KEY,
+    "STORAGE" = "s3",
+    "IMAGES_BUCKET" = yandex_storage_bucket.input-bucket.bucket,
+    "FACES_BUCKET" = yandex_storage_bucket.faces-bucket.bucket,
+    "EDENAI_API_TOKEN" = var.EDENAI_API_TOKEN
   }
 
   service_account_id = yandex_iam_service_account.func-bot-account.id
 
-  storage_mounts {
-    mount_point_name = "images"
-    bucket = yandex_storage_bucket.input-bucket.bucket
-    prefix           = ""
-  }
-
   content {
     zip_filename = archive_file.zip.output_path
   }
@@ -110,11 +134,91 @@ resource "yandex_function_trigger" "input_trigger" {
   }
 }
 
+resource "yandex_function_trigger" "input_trigger_png" {
+  name        = "vvot14-photo-png"
+  description = "Триггер для запуска обработчика vvot14-face-detection"
+  function {
+    id                 = yandex_function.face-detect.id
+    service_account_id = yandex_iam_service_account.func-bot-account.id
+    retry_attempts     = 2
+    retry_interval = 10
+  }
+  object_storage {
+    bucket_id    = yandex_storage_bucket.input-bucket.id
+    suffix       = ".png"
+    create       = true
+    update       = false
+    delete       = false
+    batch_cutoff = 2
+  }
+}
+
+resource "yandex_function_trigger" "input_trigger_webp" {
+  name        = "vvot14-photo-webp"
+  description = "Триггер для запуска обработчика vvot14-face-detection"
+  function {
+    id                 = yandex_function.face-detect.id
+    service_account_id = yandex_iam_service_account.func-bot-account.id
+    retry_attempts     = 2
+    retry_interval = 10
+  }
+  object_storage {
+    bucket_id    = yandex_storage_bucket.input-bucket.id
+    suffix       = ".webp"
+    create       = true
+    update       = false
+    delete       = false
+    batch_cutoff = 2
+  }
+}
+
 resource "yandex_message_queue" "task_queue" {
   name                        = local.queue_name
   visibility_timeout_seconds  = 600
   receive_wait_time_seconds   = 20
   message_retention_seconds   = 1209600
+  # face_cut сам перекладывает упавшие задачи; сюда попадают пачки, на которых падает вся функция
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = yandex_message_queue.dead_letter_queue.arn
+    maxReceiveCount     = 5
+  })
+  access_key = yandex_iam_service_account_static_access_key.queue-static-key.access_key
+  secret_key = yandex_iam_service_account_static_access_key.queue-static-key.secret_key
+}
+
+# фото, на которых face_detection не смог найти лица с первого раза; их повторяет та же функция
+resource "yandex_message_queue" "detect_queue" {
+  name                        = local.detect_queue_name
+  visibility_timeout_seconds  = 60
+  receive_wait_time_seconds   = 20
+  message_retention_seconds   = 1209600
+  redrive_policy = jsonencode({
+    deadLetterTargetArn = yandex_message_queue.dead_letter_queue.arn
+    maxReceiveCount     = 5
+  })
+  access_key = yandex_iam_service_account_static_access_key.queue-static-key.access_key
+  secret_key = yandex_iam_service_account_static_access_key.queue-static-key.secret_key
+}
+
+resource "yandex_function_trigger" "detect_retry_trigger" {
+  name        = "vvot14-detect"
+
+  message_queue {
+    queue_id = yandex_message_queue.detect_queue.arn
+    batch_cutoff = "5"
+    batch_size = "5"
+    service_account_id = yandex_iam_service_account.func-bot-account.id
+  }
+  function {
+    id = yandex_function.face-detect.id
+    service_account_id = yandex_iam_service_account.func-bot-account.id
+  }
+}
+
+# задачи face_cut и face_detection, которые не удалось обработать: причина - в атрибутах сообщения, см. cmd/dlq
+resource "yandex_message_queue" "dead_letter_queue" {
+  name                        = local.dlq_name
+  message_retention_seconds   = 1209600
   access_key = yandex_iam_service_account_static_access_key.queue-static-key.access_key
   secret_key = yandex_iam_service_account_static_access_key.queue-static-key.secret_key
 }
@@ -140,22 +244,27 @@ resource "yandex_function" "face-cut" {
   environment = {
     "YDB_URL" = yandex_ydb_database_serverless.face-img-db.ydb_full_endpoint,
     "AWS_ACCESS_KEY_ID"=yandex_iam_service_account_static_access_key.queue-static-key.access_key
-    "AWS_SECRET_ACCESS_KEY"=yandex_iam_service_account_static_access_key.queue-static-key.secret_key
+    "AWS_SECRET_ACCESS_KEY"=yandex_iam_service_account_static_access_key.queue-static-key.secret_key,
+    "FACE_MATCH_THRESHOLD" = "0.6",
+    "QUEUE_URL" = yandex_message_queue.task_queue.id,
+    "DLQ_URL" = yandex_message_queue.dead_letter_queue.id,
+    "MAX_ATTEMPTS" = "3",
+    # без FACE_MARGIN, FACE_SIZE и FACE_MIN_SIZE лица вырезаются ровно по границам детектора, как раньше
+    "FACE_MARGIN" = "0.2",
+    "FACE_SIZE" = "256",
+    "FACE_MIN_SIZE" = "40",
+    # выравнивание по глазам требует сборки с -tags dlib и MODELS_DIR с shape_predictor_68_face_landmarks.dat
+    "FACE_ALIGN" = "false",
+    "TG_API_KEY" = var.TG_API_KEY,
+    "STORAGE" = "s3",
+    "IMAGES_BUCKET" = yandex_storage_bucket.input-bucket.bucket,
+    "FACES_BUCKET" = yandex_storage_bucket.faces-bucket.bucket
   }
 
   service_account_id = yandex_iam_service_account.func-bot-account.id
 
-  storage_mounts {
-    mount_point_name = "images"
-    bucket = yandex_storage_bucket.input-bucket.bucket
-    prefix           = ""
-  }
-
-  storage_mounts {
-    mount_point_name = "faces"
-    bucket = yandex_storage_bucket.faces-bucket.bucket
-    prefix           = ""
-  }
+  # функции проверяют версию схемы при старте
+  depends_on = [null_resource.migrate]
 
   content {
     zip_filename = archive_file.faces-src.output_path
@@ -179,46 +288,16 @@ resource "yandex_function_trigger" "ymq_trigger" {
 
 resource "yandex_api_gateway" "api-gateway" {
   name        = "vvot14-apigw"
-  description = "API - шлюз для доступа к бакету faces"
+  description = "API - шлюз для доступа к бакетам и REST API лиц"
   labels      = {
     label       = "label"
     empty-label = ""
   }
-  spec = <<-EOT
-    openapi: "3.0.0"
-    info:
-      version: 1.0.0
-      title: Face API
-    paths:
-      /:
-        get:
-          summary: Serve static file from Yandex Cloud Object Storage
-          parameters:
-            - name: face
-              in: query
-              required: false
-              schema:
-                type: string
-            - name: image
-              in: query
-              required: false
-              schema:
-                type: string
-          responses:
-            "200":
-              description: File
-              content:
-                image/jpeg:
-                  schema:
-                    type: string
-                    format: binary
-          x-yc-apigateway-integration:
-            type: cloud_functions
-            payload_format_version: '0.1'
-            function_id: ${yandex_function.api-gw.id}
-            tag: $latest
-            service_account_id: ${yandex_iam_service_account.func-bot-account.id}
-  EOT
+  # спецификация генерируется из api_gw: cd internal/api_gw && go run -tags openapi . > openapi.json
+  spec = templatefile("internal/api_gw/openapi.json", {
+    function_id        = yandex_function.api-gw.id
+    service_account_id = yandex_iam_service_account.func-bot-account.id
+  })
 }
 
 resource "yandex_ydb_database_serverless" "face-img-db" {
@@ -233,40 +312,50 @@ resource "yandex_ydb_database_serverless" "face-img-db" {
   }
 }
 
-resource "yandex_ydb_table" "relations_table" {
-  path = "relations"
-  connection_string = yandex_ydb_database_serverless.face-img-db.ydb_full_endpoint
+# Таблицы создаёт cmd/migrate по internal/repository/migrations. Прежние yandex_ydb_table
+# убираются из состояния без удаления самих таблиц: 0001 подхватывает names и relations исходного
+# main.tf, недостающие колонки и таблицы добавляют следующие миграции
+removed {
+  from = yandex_ydb_table.relations_table
 
-  column {
-    name = "ImageID"
-    type = "String"
-    not_null = true
+  lifecycle {
+    destroy = false
   }
-  column {
-    name = "FaceID"
-    type = "String"
-    not_null = true
+}
+
+removed {
+  from = yandex_ydb_table.names_table
+
+  lifecycle {
+    destroy = false
   }
+}
+
+removed {
+  from = yandex_ydb_table.sent_faces_table
 
-  primary_key = ["FaceID"]
+  lifecycle {
+    destroy = false
+  }
 }
 
-resource "yandex_ydb_table" "names_table" {
-  path = "names"
-  connection_string = yandex_ydb_database_serverless.face-img-db.ydb_full_endpoint
+removed {
+  from = yandex_ydb_table.uploads_table
 
-  column {
-    name = "FaceName"
-    type = "String"
-    not_null = false
+  lifecycle {
+    destroy = false
   }
-  column {
-    name = "FaceID"
-    type = "String"
-    not_null = true
+}
+
+resource "null_resource" "migrate" {
+  triggers = {
+    migrations = sha1(join("", [for f in fileset("internal/repository/migrations", "*.yql") : filesha1("internal/repository/migrations/${f}")]))
   }
 
-  primary_key = ["FaceID"]
+  provisioner "local-exec" {
+    working_dir = "cmd/migrate"
+    command     = "YDB_ACCESS_TOKEN=$(yc iam create-token) go run . -url ${yandex_ydb_database_serverless.face-img-db.ydb_full_endpoint} up"
+  }
 }
 
 resource "archive_file" "bot" {
@@ -294,21 +383,20 @@ resource "yandex_function" "bot" {
   environment = {
     "TG_API_KEY" = var.TG_API_KEY,
     "YDB_URL" = yandex_ydb_database_serverless.face-img-db.ydb_full_endpoint,
-    "API_GW_URL" = yandex_api_gateway.api-gateway.domain
+    "API_GW_URL" = yandex_api_gateway.api-gateway.domain,
+    "FIND_PAGE_SIZE" = "30",
+    "QUICK_NAMES" = "4",
+    "URL_SIGNING_KEY" = var.URL_SIGNING_KEY,
+    "AWS_ACCESS_KEY_ID"=yandex_iam_service_account_static_access_key.queue-static-key.access_key
+    "AWS_SECRET_ACCESS_KEY"=yandex_iam_service_account_static_access_key.queue-static-key.secret_key,
+    "STORAGE" = "s3",
+    "IMAGES_BUCKET" = yandex_storage_bucket.input-bucket.bucket,
+    "FACES_BUCKET" = yandex_storage_bucket.faces-bucket.bucket
   }
   service_account_id = yandex_iam_service_account.func-bot-account.id
 
-  storage_mounts {
-    mount_point_name = "faces"
-    bucket = yandex_storage_bucket.faces-bucket.bucket
-    prefix           = ""
-  }
-
-  storage_mounts {
-    mount_point_name = "images"
-    bucket = yandex_storage_bucket.input-bucket.bucket
-    prefix           = ""
-  }
+  # функции проверяют версию схемы при старте
+  depends_on = [null_resource.migrate]
 
   content {
     zip_filename = archive_file.bot.output_path
@@ -322,19 +410,20 @@ resource "yandex_function" "api-gw" {
   entrypoint  = "index.Handler"
   memory      = 128
   execution_timeout  = 10
-  service_account_id = yandex_iam_service_account.func-bot-account.id
-
-  storage_mounts {
-    mount_point_name = "faces"
-    bucket = yandex_storage_bucket.faces-bucket.bucket
-    prefix           = ""
+  environment = {
+    "AWS_ACCESS_KEY_ID"=yandex_iam_service_account_static_access_key.queue-static-key.access_key
+    "AWS_SECRET_ACCESS_KEY"=yandex_iam_service_account_static_access_key.queue-static-key.secret_key,
+    "URL_SIGNING_KEY" = var.URL_SIGNING_KEY,
+    "API_TOKEN" = var.API_TOKEN,
+    "YDB_URL" = yandex_ydb_database_serverless.face-img-db.ydb_full_endpoint,
+    "STORAGE" = "s3",
+    "IMAGES_BUCKET" = yandex_storage_bucket.input-bucket.bucket,
+    "FACES_BUCKET" = yandex_storage_bucket.faces-bucket.bucket
   }
+  service_account_id = yandex_iam_service_account.func-bot-account.id
 
-  storage_mounts {
-    mount_point_name = "images"
-    bucket = yandex_storage_bucket.input-bucket.bucket
-    prefix           = ""
-  }
+  # функции проверяют версию схемы при старте
+  depends_on = [null_resource.migrate]
 
   content {
     zip_filename = archive_file.gw.output_path